@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/mattcarlotta/nvi-api/database"
+	"github.com/mattcarlotta/nvi-api/models"
+)
+
+func main() {
+	db := database.GetConnection()
+
+	chainIDs, err := models.GetAuditChainIDs(db)
+	if err != nil {
+		log.Fatalf("Unable to find the audit chains: %s", err.Error())
+	}
+
+	broken := 0
+	for _, chainID := range chainIDs {
+		result, err := models.VerifyAuditChain(db, chainID, 1000)
+		if err != nil {
+			log.Fatalf("Unable to verify the %s audit chain: %s", chainID, err.Error())
+		}
+
+		if !result.Valid {
+			fmt.Printf(
+				"❌ The %s audit chain is broken at event %d (%s): %s\n",
+				chainID, result.Break.Sequence, result.Break.EventID, result.Break.Reason,
+			)
+			fmt.Printf("%d events were verified before the break\n", result.Checked)
+			broken++
+			continue
+		}
+
+		fmt.Printf("🔗 The %s audit chain is intact, %d events were verified\n", chainID, result.Checked)
+		fmt.Printf("Head hash: %x\n", result.HeadHash)
+	}
+
+	if broken > 0 {
+		fmt.Printf("%d of %d audit chains are broken\n", broken, len(chainIDs))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mattcarlotta/nvi-api/database"
+	"github.com/mattcarlotta/nvi-api/models"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/datatypes"
+)
+
+func GetAllAPIKeys(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	var apiKeys []models.APIKey
+	db.Where(&models.APIKey{UserID: userSessionID}).Order("created_at").Find(&apiKeys)
+
+	return c.Status(fiber.StatusOK).JSON(apiKeys)
+}
+
+func CreateAPIKey(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	var data models.ReqCreateAPIKey
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.CreateAPIKeyInvalidBody))
+	}
+
+	if err := utils.Validate().Struct(data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.CreateAPIKeyInvalidBody))
+	}
+
+	if data.ExpiresAt != nil && data.ExpiresAt.Before(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.CreateAPIKeyInvalidBody))
+	}
+
+	// revoked keys' names can be reused
+	if err := db.Where(
+		&models.APIKey{UserID: userSessionID, Name: data.Name},
+	).Where("revoked_at IS NULL").First(&models.APIKey{}).Error; err == nil {
+		return c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.CreateAPIKeyNameTaken))
+	}
+
+	projectIDs, _ := utils.ParseUUIDs(data.ProjectIDs)
+	if len(projectIDs) > 0 {
+		var projectCount int64
+		db.Model(&models.Project{}).Scopes(
+			models.MemberProjects(userSessionID),
+		).Where("id IN ?", projectIDs).Count(&projectCount)
+		if int(projectCount) != len(projectIDs) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.CreateAPIKeyNonExistentProject))
+		}
+	}
+
+	environmentIDs, _ := utils.ParseUUIDs(data.EnvironmentIDs)
+	if len(environmentIDs) > 0 {
+		var environmentCount int64
+		db.Model(&models.Environment{}).Scopes(
+			models.MemberProjectResources(userSessionID),
+		).Where("id IN ?", environmentIDs).Count(&environmentCount)
+		if int(environmentCount) != len(environmentIDs) {
+			return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.CreateAPIKeyNonExistentEnv))
+		}
+	}
+
+	newAPIKey := models.APIKey{
+		UserID:         userSessionID,
+		Name:           data.Name,
+		ProjectIDs:     datatypes.NewJSONSlice(projectIDs),
+		EnvironmentIDs: datatypes.NewJSONSlice(environmentIDs),
+		Access:         data.Access,
+		ExpiresAt:      data.ExpiresAt,
+	}
+	if err := db.Create(&newAPIKey).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:      models.AuditAPIKeyCreate,
+		ResourceIDs: models.AuditResources(newAPIKey.ID),
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(newAPIKey)
+}
+
+func RevokeAPIKey(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	id := c.Params("id")
+	if err := utils.Validate().Var(id, "required,uuid"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.RevokeAPIKeyInvalidID))
+	}
+
+	var apiKey models.APIKey
+	if err := db.Where(
+		&models.APIKey{ID: utils.MustParseUUID(id), UserID: userSessionID},
+	).Where("revoked_at IS NULL").First(&apiKey).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.RevokeAPIKeyNonExistentID))
+	}
+
+	if err := db.Model(&apiKey).Update("revoked_at", time.Now()).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:      models.AuditAPIKeyRevoke,
+		ResourceIDs: models.AuditResources(apiKey.ID),
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(apiKey)
+}
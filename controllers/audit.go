@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mattcarlotta/nvi-api/database"
+	"github.com/mattcarlotta/nvi-api/models"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/gorm"
+)
+
+// the actor is the API key's owner or the session's user, when the event doesn't already have one
+func recordAuditEvent(c *fiber.Ctx, db *gorm.DB, event models.AuditEvent) error {
+	if apiKey, ok := c.Locals("apiKey").(models.APIKey); ok {
+		event.ActorUserID = apiKey.UserID
+		event.APIKeyID = &apiKey.ID
+	} else if userSessionID, ok := c.Locals("userSessionID").(uuid.UUID); ok {
+		event.ActorUserID = userSessionID
+	}
+
+	event.IP = c.IP()
+	event.UserAgent = c.Get(fiber.HeaderUserAgent)
+
+	return models.AppendAuditEvent(db.Session(&gorm.Session{NewDB: true}), &event)
+}
+
+func GetAuditEventsByProjectID(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	id := c.Params("id")
+	if err := utils.Validate().Var(id, "required,uuid"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.GetAuditEventsInvalidID))
+	}
+
+	var data models.ReqGetAuditEvents
+	if err := c.QueryParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.GetAuditEventsInvalidQuery))
+	}
+
+	if err := utils.Validate().Struct(data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.GetAuditEventsInvalidQuery))
+	}
+
+	var project models.Project
+	if err := db.Scopes(
+		models.MemberProjects(userSessionID),
+	).Where(
+		&models.Project{ID: utils.MustParseUUID(id)},
+	).First(&project).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.GetAuditEventsNonExistentProject))
+	}
+
+	if _, ok := models.Authorize(db, userSessionID, project.OrganizationID, utils.ReadAuditLog); !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	query := db.Model(&models.AuditEvent{}).Where("project_id = ?", project.ID)
+	if len(data.Action) > 0 {
+		query = query.Where("action = ?", data.Action)
+	}
+	if len(data.ActorID) > 0 {
+		query = query.Where("actor_user_id = ?", utils.MustParseUUID(data.ActorID))
+	}
+	if len(data.ResourceID) > 0 {
+		query = query.Where("resource_ids @> ?", `["`+utils.MustParseUUID(data.ResourceID).String()+`"]`)
+	}
+	if len(data.From) > 0 {
+		from, _ := time.Parse(time.RFC3339, data.From)
+		query = query.Where("created_at >= ?", from)
+	}
+	if len(data.To) > 0 {
+		to, _ := time.Parse(time.RFC3339, data.To)
+		query = query.Where("created_at <= ?", to)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	page := data.Page
+	if page == 0 {
+		page = 1
+	}
+
+	limit := data.Limit
+	if limit == 0 {
+		limit = 25
+	}
+
+	var events []models.AuditEvent
+	if err := query.Order("created_at DESC, sequence DESC").Offset((page - 1) * limit).Limit(limit).Find(&events).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(
+		fiber.Map{"events": events, "page": page, "limit": limit, "total": total},
+	)
+}
+
+func VerifyAuditChainByProjectID(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	id := c.Params("id")
+	if err := utils.Validate().Var(id, "required,uuid"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.VerifyAuditChainInvalidID))
+	}
+
+	var project models.Project
+	if err := db.Scopes(
+		models.MemberProjects(userSessionID),
+	).Where(
+		&models.Project{ID: utils.MustParseUUID(id)},
+	).First(&project).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.VerifyAuditChainNonExistentProject))
+	}
+
+	if _, ok := models.Authorize(db, userSessionID, project.OrganizationID, utils.ReadAuditLog); !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	result, err := models.VerifyAuditChain(db, project.ID, 500)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
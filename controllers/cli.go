@@ -2,25 +2,59 @@ package controllers
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/mattcarlotta/nvi-api/database"
 	"github.com/mattcarlotta/nvi-api/models"
 	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/gorm"
 )
 
-// TODO(carlotta): add rate limits to all these endpoints to prevent brute forcing
-func GetSecretsByAPIKey(c *fiber.Ctx) error {
-	db := database.GetConnection()
-	apiKey := utils.GetAPIKey(c)
+func getAPIKey(c *fiber.Ctx) models.APIKey {
+	return c.Locals("apiKey").(models.APIKey)
+}
 
-	var user models.User
-	if err := db.Where(&models.User{APIKey: apiKey}).First(&user).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).SendString(
-			"the provided apiKey is not valid. please try again",
+// the "organization" query chooses between projects with the same name
+func findProjectByName(c *fiber.Ctx, db *gorm.DB, apiKey *models.APIKey, projectName string) (models.Project, *fiber.Error) {
+	query := db.Scopes(
+		models.MemberProjects(apiKey.UserID), apiKey.ProjectScope,
+	).Where(&models.Project{Name: projectName})
+
+	if organizationName := c.Query("organization"); len(organizationName) > 0 {
+		if err := utils.Validate().Var(organizationName, "name,lte=255"); err != nil {
+			return models.Project{}, fiber.NewError(
+				fiber.StatusBadRequest, "a valid organization name must be supplied in order to choose a project",
+			)
+		}
+
+		query = query.Where(
+			"organization_id IN (?)",
+			db.Model(&models.Organization{}).Select("id").Where(&models.Organization{Name: organizationName}),
 		)
 	}
 
+	var projects []models.Project
+	if err := query.Limit(2).Find(&projects).Error; err != nil || len(projects) == 0 {
+		return models.Project{}, fiber.NewError(fiber.StatusNotFound, "unable to locate a project with the provided name")
+	}
+
+	if len(projects) > 1 {
+		return models.Project{}, fiber.NewError(
+			fiber.StatusConflict,
+			fmt.Sprintf("the '%s' project exists within multiple organizations, please supply an organization name", projectName),
+		)
+	}
+
+	return projects[0], nil
+}
+
+// TODO(carlotta): add rate limits to all these endpoints to prevent brute forcing
+func GetSecretsByAPIKey(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	apiKey := getAPIKey(c)
+
 	projectName := c.Query("project")
 	if err := utils.Validate().Var(projectName, "required,name,lte=255"); err != nil {
 		return c.Status(fiber.StatusBadRequest).SendString(
@@ -28,13 +62,9 @@ func GetSecretsByAPIKey(c *fiber.Ctx) error {
 		)
 	}
 
-	var project models.Project
-	if err := db.Where(
-		&models.Project{Name: projectName, UserID: user.ID},
-	).First(&project).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).SendString(
-			"unable to locate a project with the provided name",
-		)
+	project, findErr := findProjectByName(c, db, &apiKey, projectName)
+	if findErr != nil {
+		return c.Status(findErr.Code).SendString(findErr.Message)
 	}
 
 	environmentName := c.Query("environment")
@@ -45,17 +75,58 @@ func GetSecretsByAPIKey(c *fiber.Ctx) error {
 	}
 
 	var environment models.Environment
-	if err := db.Where(
-		&models.Environment{Name: environmentName, ProjectID: project.ID, UserID: user.ID},
+	if err := db.Scopes(apiKey.EnvironmentScope).Where(
+		&models.Environment{Name: environmentName, ProjectID: project.ID},
 	).First(&environment).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).SendString(
 			fmt.Sprintf("unable to locate a '%s' environment within the '%s' project", environmentName, projectName),
 		)
 	}
 
+	if !apiKey.CanReadEnvironmentSecrets(db, &environment) {
+		return c.Status(fiber.StatusForbidden).SendString(
+			fmt.Sprintf("the API key isn't scoped to every environment the '%s' environment inherits from", environmentName),
+		)
+	}
+
+	member, ok := models.AuthorizeProject(db, apiKey.UserID, project.ID, utils.ReadResources)
+	if !ok || !member.CanReadEnvironmentSecrets(db, &environment) {
+		return c.Status(fiber.StatusForbidden).SendString(
+			fmt.Sprintf("you don't have access to the '%s' environment's secrets", environmentName),
+		)
+	}
+
+	format := c.Query("format", "dotenv")
+	// end-to-end encrypted values are sent as ciphertexts, which only json can carry
+	if project.EndToEnd {
+		format = c.Query("format", "json")
+		if format != "json" {
+			return c.Status(fiber.StatusConflict).SendString(
+				fmt.Sprintf("the '%s' project is end-to-end encrypted, so its secrets can only be served as json", projectName),
+			)
+		}
+	}
+
+	if err := utils.Validate().Var(format, "oneof="+strings.Join(utils.SecretFormats, " ")); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			fmt.Sprintf("a valid format must be supplied; it must be one of the following: %s", strings.Join(utils.SecretFormats, ", ")),
+		)
+	}
+
+	etag, err := models.GetEnvironmentSecretsETag(db, &environment, format, project.Name, environment.Name)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	// nothing is served, so the read isn't audited
+	if ifNoneMatch := c.Get(fiber.HeaderIfNoneMatch); len(ifNoneMatch) > 0 && utils.MatchesETag(ifNoneMatch, etag) {
+		c.Set(fiber.HeaderETag, etag)
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
 	var secrets []models.SecretResult
 	if err := db.Raw(
-		utils.FindSecretsByEnvIDQuery, user.ID, utils.GenerateJSONIDString(environment.ID),
+		utils.FindSecretsByEnvIDQuery, project.ID, environment.ID,
 	).Scan(&secrets).Error; err != nil || len(secrets) == 0 {
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
@@ -66,32 +137,71 @@ func GetSecretsByAPIKey(c *fiber.Ctx) error {
 		)
 	}
 
-	var stringifiedSecrets string
-	for _, secret := range secrets {
-		decryptedValue, err := utils.DecryptSecretValue(secret.Value, secret.Nonce)
+	envVars, err := openSecrets(&project, secrets)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	var referencedIDs []uuid.UUID
+	// end-to-end encrypted values can't be resolved by the server
+	if !project.EndToEnd {
+		loaded := make(loadedEnvironments)
+		resolver := utils.NewSecretResolver(secretEnvironmentLoader(db, apiKey.UserID, project.OrganizationID, &apiKey, loaded))
+		envVars, err = resolver.Resolve(project.Name, environment.Name, envVars)
+		if err != nil {
+			if _, ok := utils.SecretReferenceErrorCode(err); ok {
+				return c.Status(fiber.StatusUnprocessableEntity).SendString(err.Error())
+			}
+			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+		}
+
+		// the ETag only covers the environment's own secrets
+		if resolver.LoadedOtherEnvironments() {
+			etag = ""
+		}
+
+		referencedIDs, err = recordReferencedSecretReads(c, db, models.AuditSecretCLIRead, project.ID, resolver, loaded)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 		}
-		stringifiedSecrets += secret.Key + "=" + string(decryptedValue) + "\n"
 	}
 
-	return c.Status(fiber.StatusOK).SendString(stringifiedSecrets)
+	formattedSecrets, contentType, err := utils.FormatSecrets(format, envVars, project.Name+"-"+environment.Name)
+	if err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).SendString(err.Error())
+	}
+
+	secretIDs := make([]uuid.UUID, 0, len(secrets)+len(referencedIDs)+1)
+	for _, secret := range secrets {
+		secretIDs = append(secretIDs, secret.ID)
+	}
+	secretIDs = append(secretIDs, referencedIDs...)
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:      models.AuditSecretCLIRead,
+		ProjectID:   &project.ID,
+		ResourceIDs: models.AuditResources(append(secretIDs, environment.ID)...),
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	if project.EndToEnd {
+		c.Set("X-End-To-End-Encrypted", "true")
+	}
+	if len(etag) > 0 {
+		c.Set(fiber.HeaderETag, etag)
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	return c.Status(fiber.StatusOK).SendString(formattedSecrets)
 }
 
 func GetProjectsByAPIKey(c *fiber.Ctx) error {
 	db := database.GetConnection()
-	apiKey := utils.GetAPIKey(c)
-
-	var user models.User
-	if err := db.Where(&models.User{APIKey: apiKey}).First(&user).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).SendString(
-			"the provided apiKey is not valid. please try again",
-		)
-	}
+	apiKey := getAPIKey(c)
 
 	var projects []models.Project
-	if err := db.Where(
-		&models.Project{UserID: user.ID},
+	if err := db.Scopes(
+		models.MemberProjects(apiKey.UserID), apiKey.ProjectScope,
 	).Find(&projects).Error; err != nil || len(projects) == 0 {
 		return c.Status(fiber.StatusNotFound).SendString(
 			"unable to locate any projects",
@@ -108,14 +218,7 @@ func GetProjectsByAPIKey(c *fiber.Ctx) error {
 
 func GetEnvironmentsByAPIKey(c *fiber.Ctx) error {
 	db := database.GetConnection()
-	apiKey := utils.GetAPIKey(c)
-
-	var user models.User
-	if err := db.Where(&models.User{APIKey: apiKey}).First(&user).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).SendString(
-			"the provided apiKey is not valid. please try again",
-		)
-	}
+	apiKey := getAPIKey(c)
 
 	projectName := c.Query("project")
 	if err := utils.Validate().Var(projectName, "required,name,lte=255"); err != nil {
@@ -124,27 +227,29 @@ func GetEnvironmentsByAPIKey(c *fiber.Ctx) error {
 		)
 	}
 
-	var project models.Project
-	if err := db.Where(
-		&models.Project{Name: projectName, UserID: user.ID},
-	).First(&project).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).SendString(
-			"unable to locate a project with the provided name",
-		)
+	project, findErr := findProjectByName(c, db, &apiKey, projectName)
+	if findErr != nil {
+		return c.Status(findErr.Code).SendString(findErr.Message)
 	}
 
 	var environments []models.Environment
-	if err := db.Where(
-		&models.Environment{ProjectID: project.ID, UserID: user.ID},
-	).Find(&environments).Error; err != nil || len(environments) == 0 {
-		return c.Status(fiber.StatusNotFound).SendString(
-			fmt.Sprintf("unable to locate any environments within the '%s' project", projectName),
-		)
+	if err := db.Scopes(apiKey.EnvironmentScope).Where(
+		&models.Environment{ProjectID: project.ID},
+	).Find(&environments).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 	}
 
 	var stringifiedEnvironments string
 	for _, e := range environments {
-		stringifiedEnvironments += e.Name + "\n"
+		if apiKey.CanReadEnvironmentSecrets(db, &e) {
+			stringifiedEnvironments += e.Name + "\n"
+		}
+	}
+
+	if len(stringifiedEnvironments) == 0 {
+		return c.Status(fiber.StatusNotFound).SendString(
+			fmt.Sprintf("unable to locate any environments within the '%s' project", projectName),
+		)
 	}
 
 	return c.Status(fiber.StatusOK).SendString(stringifiedEnvironments)
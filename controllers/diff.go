@@ -0,0 +1,342 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mattcarlotta/nvi-api/database"
+	"github.com/mattcarlotta/nvi-api/models"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/gorm"
+)
+
+func getEnvironmentValues(db *gorm.DB, project *models.Project, environment *models.Environment) (map[string]string, error) {
+	var secrets []models.SecretResult
+	if err := db.Raw(
+		utils.FindSecretsByEnvIDQuery, project.ID, environment.ID,
+	).Scan(&secrets).Error; err != nil {
+		return nil, err
+	}
+
+	envVars, err := openSecrets(project, secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(envVars))
+	for _, envVar := range envVars {
+		values[envVar.Key] = envVar.Value
+	}
+
+	return values, nil
+}
+
+// inherited values aren't included since the history of a parent isn't kept
+func getOwnEnvironmentValues(
+	db *gorm.DB, project *models.Project, environment *models.Environment, at *time.Time,
+) (map[string]string, error) {
+	values := make(map[string]string)
+
+	if at != nil {
+		revisions, err := models.GetEnvironmentRevisionsAt(db, environment, *at)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, revision := range revisions {
+			value, err := revision.OpenValue(project, environment.ID)
+			if err != nil {
+				return nil, err
+			}
+			values[revision.Key] = value
+		}
+
+		return values, nil
+	}
+
+	var secrets []models.SecretResult
+	if err := db.Raw(
+		utils.FindOwnSecretsByEnvIDQuery, project.ID, environment.ID,
+	).Scan(&secrets).Error; err != nil {
+		return nil, err
+	}
+
+	for _, secret := range secrets {
+		value, err := project.OpenSecretResult(&secret)
+		if err != nil {
+			return nil, err
+		}
+		values[secret.Key] = value
+	}
+
+	return values, nil
+}
+
+// only members who can write secrets are shown masked values, everyone else sees hashes
+func secretValuePresenter(members ...models.OrganizationMember) (func(string) string, string, error) {
+	for _, member := range members {
+		if !utils.RoleHasPermission(member.Role, utils.WriteSecrets) {
+			hash, err := utils.NewSecretValueHasher()
+			return hash, "hashed", err
+		}
+	}
+
+	return utils.MaskSecretValue, "masked", nil
+}
+
+func getDiffEnvironment(
+	db *gorm.DB, userID uuid.UUID, environment *models.Environment,
+) (models.Project, models.OrganizationMember, utils.ErrorResponseCode, int) {
+	member, ok := models.AuthorizeProject(db, userID, environment.ProjectID, utils.ReadResources)
+	if !ok {
+		return models.Project{}, member, utils.PermissionDenied, fiber.StatusForbidden
+	}
+
+	if !member.CanReadEnvironmentSecrets(db, environment) {
+		return models.Project{}, member, utils.EnvironmentPermissionDenied, fiber.StatusForbidden
+	}
+
+	var project models.Project
+	if err := db.First(&project, "id=?", environment.ProjectID).Error; err != nil {
+		return project, member, utils.DiffSecretsNonExistentEnvironment, fiber.StatusNotFound
+	}
+
+	if project.EndToEnd {
+		return project, member, utils.EndToEndUnsupported, fiber.StatusConflict
+	}
+
+	return project, member, utils.Unknown, fiber.StatusOK
+}
+
+func DiffSecrets(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	from := c.Query("from")
+	to := c.Query("to")
+	at := c.Query("at")
+	if err := utils.Validate().Var(from, "required,uuid"); err != nil ||
+		(len(to) > 0) == (len(at) > 0) ||
+		(len(to) > 0 && utils.Validate().Var(to, "uuid") != nil) {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.DiffSecretsInvalidQuery))
+	}
+
+	var timestamp *time.Time
+	if len(at) > 0 {
+		parsedTime, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.DiffSecretsInvalidQuery))
+		}
+		timestamp = &parsedTime
+	}
+
+	var fromEnvironment models.Environment
+	if err := db.Scopes(
+		models.MemberProjectResources(userSessionID),
+	).Where(
+		&models.Environment{ID: utils.MustParseUUID(from)},
+	).First(&fromEnvironment).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.DiffSecretsNonExistentEnvironment))
+	}
+
+	fromProject, fromMember, code, status := getDiffEnvironment(db, userSessionID, &fromEnvironment)
+	if status != fiber.StatusOK {
+		return c.Status(status).JSON(utils.JSONError(code))
+	}
+
+	var fromValues, toValues map[string]string
+	var err error
+	members := []models.OrganizationMember{fromMember}
+	resourceIDs := []uuid.UUID{fromEnvironment.ID}
+	toEnvironment := fromEnvironment
+
+	if timestamp != nil {
+		if fromValues, err = getOwnEnvironmentValues(db, &fromProject, &fromEnvironment, timestamp); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+		}
+
+		if toValues, err = getOwnEnvironmentValues(db, &fromProject, &fromEnvironment, nil); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+		}
+	} else {
+		if err := db.Scopes(
+			models.MemberProjectResources(userSessionID),
+		).Where(
+			&models.Environment{ID: utils.MustParseUUID(to)},
+		).First(&toEnvironment).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.DiffSecretsNonExistentEnvironment))
+		}
+
+		toProject, toMember, code, status := getDiffEnvironment(db, userSessionID, &toEnvironment)
+		if status != fiber.StatusOK {
+			return c.Status(status).JSON(utils.JSONError(code))
+		}
+		members = append(members, toMember)
+		resourceIDs = append(resourceIDs, toEnvironment.ID)
+
+		if fromValues, err = getEnvironmentValues(db, &fromProject, &fromEnvironment); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+		}
+
+		if toValues, err = getEnvironmentValues(db, &toProject, &toEnvironment); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+		}
+	}
+
+	present, valueFormat, err := secretValuePresenter(members...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:      models.AuditSecretDiff,
+		ProjectID:   &fromEnvironment.ProjectID,
+		ResourceIDs: models.AuditResources(resourceIDs...),
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"from":        fromEnvironment,
+		"to":          toEnvironment,
+		"at":          timestamp,
+		"valueFormat": valueFormat,
+		"diff":        utils.DiffSecretValues(fromValues, toValues, present),
+	})
+}
+
+func DiffSecretsByAPIKey(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	apiKey := getAPIKey(c)
+
+	projectName := c.Query("project")
+	if err := utils.Validate().Var(projectName, "required,name,lte=255"); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			"a valid project name must be supplied in order to compare secrets",
+		)
+	}
+
+	environmentName := c.Query("environment")
+	if err := utils.Validate().Var(environmentName, "required,name,lte=255"); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			"a valid environment name must be supplied in order to compare secrets",
+		)
+	}
+
+	compareProjectName := c.Query("compareProject", projectName)
+	compareEnvironmentName := c.Query("compareEnvironment")
+	at := c.Query("at")
+	if (len(compareEnvironmentName) > 0) == (len(at) > 0) ||
+		utils.Validate().Var(compareProjectName, "name,lte=255") != nil ||
+		(len(compareEnvironmentName) > 0 && utils.Validate().Var(compareEnvironmentName, "name,lte=255") != nil) {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			"either a valid compareEnvironment name or an at timestamp must be supplied in order to compare secrets",
+		)
+	}
+
+	var timestamp *time.Time
+	if len(at) > 0 {
+		parsedTime, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("the at timestamp must be formatted as RFC3339")
+		}
+		timestamp = &parsedTime
+	}
+
+	findEnvironment := func(projectName string, environmentName string) (models.Project, models.OrganizationMember, models.Environment, *fiber.Error) {
+		project, findErr := findProjectByName(c, db, &apiKey, projectName)
+		if findErr != nil {
+			return project, models.OrganizationMember{}, models.Environment{}, findErr
+		}
+
+		var environment models.Environment
+		if err := db.Scopes(apiKey.EnvironmentScope).Where(
+			&models.Environment{Name: environmentName, ProjectID: project.ID},
+		).First(&environment).Error; err != nil {
+			return project, models.OrganizationMember{}, environment, fiber.NewError(
+				fiber.StatusNotFound,
+				fmt.Sprintf("unable to locate a '%s' environment within the '%s' project", environmentName, projectName),
+			)
+		}
+
+		if !apiKey.CanReadEnvironmentSecrets(db, &environment) {
+			return project, models.OrganizationMember{}, environment, fiber.NewError(
+				fiber.StatusForbidden,
+				fmt.Sprintf("the API key isn't scoped to every environment the '%s' environment inherits from", environmentName),
+			)
+		}
+
+		member, ok := models.AuthorizeProject(db, apiKey.UserID, project.ID, utils.ReadResources)
+		if !ok || !member.CanReadEnvironmentSecrets(db, &environment) {
+			return project, member, environment, fiber.NewError(
+				fiber.StatusForbidden, fmt.Sprintf("you don't have access to the '%s' environment's secrets", environmentName),
+			)
+		}
+
+		if project.EndToEnd {
+			return project, member, environment, fiber.NewError(
+				fiber.StatusConflict,
+				fmt.Sprintf("the '%s' project is end-to-end encrypted, so its secrets can't be compared", projectName),
+			)
+		}
+
+		return project, member, environment, nil
+	}
+
+	project, member, environment, findErr := findEnvironment(projectName, environmentName)
+	if findErr != nil {
+		return c.Status(findErr.Code).SendString(findErr.Message)
+	}
+
+	var fromValues, toValues map[string]string
+	var err error
+	members := []models.OrganizationMember{member}
+	resourceIDs := []uuid.UUID{environment.ID}
+
+	if timestamp != nil {
+		if fromValues, err = getOwnEnvironmentValues(db, &project, &environment, timestamp); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+		}
+
+		if toValues, err = getOwnEnvironmentValues(db, &project, &environment, nil); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+		}
+	} else {
+		compareProject, compareMember, compareEnvironment, findErr := findEnvironment(compareProjectName, compareEnvironmentName)
+		if findErr != nil {
+			return c.Status(findErr.Code).SendString(findErr.Message)
+		}
+		members = append(members, compareMember)
+		resourceIDs = append(resourceIDs, compareEnvironment.ID)
+
+		if fromValues, err = getEnvironmentValues(db, &project, &environment); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+		}
+
+		if toValues, err = getEnvironmentValues(db, &compareProject, &compareEnvironment); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+		}
+	}
+
+	present, _, err := secretValuePresenter(members...)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:      models.AuditSecretDiff,
+		ProjectID:   &project.ID,
+		ResourceIDs: models.AuditResources(resourceIDs...),
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	formattedDiff := utils.FormatSecretDiffs(utils.DiffSecretValues(fromValues, toValues, present))
+	if len(formattedDiff) == 0 {
+		return c.Status(fiber.StatusOK).SendString("there aren't any differences between the secrets\n")
+	}
+
+	return c.Status(fiber.StatusOK).SendString(formattedDiff)
+}
@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mattcarlotta/nvi-api/database"
+	"github.com/mattcarlotta/nvi-api/models"
+	"github.com/mattcarlotta/nvi-api/utils"
+)
+
+// any signed in user can read this, so it leaves out the key IDs and the job's error
+func GetMasterKeyRotationStatus(c *fiber.Ctx) error {
+	db := database.GetConnection()
+
+	status, err := models.GetKeyRotationStatus(db, utils.ActiveKeyProvider.KeyID())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	progress := fiber.Map{"status": nil, "total": 0, "rewrapped": 0, "remaining": status.Remaining}
+	if status.Job != nil {
+		progress["status"] = status.Job.Status
+		progress["total"] = status.Job.Total
+		progress["rewrapped"] = status.Job.Rewrapped
+	}
+
+	return c.Status(fiber.StatusOK).JSON(progress)
+}
@@ -1,13 +1,17 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/mattcarlotta/nvi-api/database"
+	"github.com/mattcarlotta/nvi-api/events"
 	"github.com/mattcarlotta/nvi-api/models"
 	"github.com/mattcarlotta/nvi-api/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func GetEnvironmentByID(c *fiber.Ctx) error {
@@ -20,12 +24,16 @@ func GetEnvironmentByID(c *fiber.Ctx) error {
 	}
 
 	var environment models.Environment
-	if err := db.Where(
-		&models.Environment{ID: utils.MustParseUUID(id), UserID: userSessionID},
+	if err := db.Scopes(
+		models.MemberProjectResources(userSessionID),
+	).Where(
+		&models.Environment{ID: utils.MustParseUUID(id)},
 	).First(&environment).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.GetEnvironmentNonExistentID))
 	}
 
+	utils.SetETag(c, environment.Version)
+
 	return c.Status(fiber.StatusOK).JSON(environment)
 }
 
@@ -39,14 +47,16 @@ func GetAllEnvironmentByProjectName(c *fiber.Ctx) error {
 	}
 
 	var project models.Project
-	if err := db.Where(
-		&models.Project{Name: projectName, UserID: userSessionID},
+	if err := db.Scopes(
+		models.MemberProjects(userSessionID), models.InOrganization(c.Query("organizationID")),
+	).Where(
+		&models.Project{Name: projectName},
 	).First(&project).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.GetProjectNonExistentName))
 	}
 
 	var environments []models.Environment
-	db.Where(&models.Environment{UserID: userSessionID, ProjectID: project.ID}).Find(&environments)
+	db.Where(&models.Environment{ProjectID: project.ID}).Find(&environments)
 
 	return c.Status(fiber.StatusOK).JSON(
 		fiber.Map{
@@ -71,8 +81,10 @@ func GetEnvironmentByNameAndProjectID(c *fiber.Ctx) error {
 	}
 
 	var environment models.Environment
-	if err := db.Where(
-		&models.Environment{Name: name, ProjectID: utils.MustParseUUID(projectID), UserID: userSessionID},
+	if err := db.Scopes(
+		models.MemberProjectResources(userSessionID),
+	).Where(
+		&models.Environment{Name: name, ProjectID: utils.MustParseUUID(projectID)},
 	).First(&environment).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.GetEnvironmentNonExistentName))
 	}
@@ -95,8 +107,10 @@ func SearchForEnvironmentsByNameAndProjectID(c *fiber.Ctx) error {
 	}
 
 	var environments []models.Environment
-	if err := db.Where(
-		"name ILIKE ? AND project_id=? AND user_id=?", "%"+name+"%", utils.MustParseUUID(projectID), userSessionID,
+	if err := db.Scopes(
+		models.MemberProjectResources(userSessionID),
+	).Where(
+		"name ILIKE ? AND project_id=?", "%"+name+"%", utils.MustParseUUID(projectID),
 	).Find(&environments).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
@@ -114,23 +128,54 @@ func CreateEnvironment(c *fiber.Ctx) error {
 	}
 
 	var project models.Project
-	if err := db.Where(&models.Project{ID: utils.MustParseUUID(data.ProjectID)}).First(&project).Error; err != nil {
+	if err := db.Scopes(
+		models.MemberProjects(userSessionID),
+	).Where(
+		&models.Project{ID: utils.MustParseUUID(data.ProjectID)},
+	).First(&project).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.CreateEnvironmentInvalidProjectID))
 	}
 
+	if _, ok := models.Authorize(db, userSessionID, project.OrganizationID, utils.ManageEnvironments); !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
 	var environmentCount int64
-	db.Model(&models.Environment{}).Where("user_id=? AND project_id=?", userSessionID, project.ID).Count(&environmentCount)
-	if environmentCount >= 10 {
+	db.Model(&models.Environment{}).Where("project_id=?", project.ID).Count(&environmentCount)
+	if environmentCount >= models.EnvironmentLimit {
 		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.CreateEnvironmentOverLimit))
 	}
 
-	newEnv := models.Environment{Name: data.Name, ProjectID: project.ID, UserID: userSessionID}
 	var environment models.Environment
-	if err := db.Where(&newEnv).First(&environment).Error; err == nil {
+	if err := db.Where(
+		&models.Environment{Name: data.Name, ProjectID: project.ID},
+	).First(&environment).Error; err == nil {
 		return c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.CreateEnvironmentNameTaken))
 	}
 
-	if err := db.Create(&newEnv).Error; err != nil {
+	newEnv := models.Environment{Name: data.Name, ProjectID: project.ID, UserID: userSessionID}
+
+	if len(data.ParentID) > 0 {
+		var parent models.Environment
+		if err := utils.Validate().Var(data.ParentID, "uuid"); err != nil || db.Where(
+			&models.Environment{ID: utils.MustParseUUID(data.ParentID), ProjectID: project.ID},
+		).First(&parent).Error != nil {
+			return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.CreateEnvironmentNonExistentParent))
+		}
+		newEnv.ParentID = &parent.ID
+	}
+
+	if err := db.Create(&newEnv).Error; errors.Is(err, gorm.ErrDuplicatedKey) {
+		return c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.CreateEnvironmentNameTaken))
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:      models.AuditEnvironmentCreate,
+		ProjectID:   &project.ID,
+		ResourceIDs: models.AuditResources(newEnv.ID),
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
 
@@ -146,35 +191,75 @@ func DeleteEnvironment(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.DeleteEnvironmentInvalidID))
 	}
 
-	return db.Transaction(func(tx *gorm.DB) error {
+	return transaction(db, func(tx *gorm.DB) error {
 		parsedID := utils.MustParseUUID(id)
 
 		var environment models.Environment
-		if err := tx.Where(
-			&models.Environment{ID: parsedID, UserID: userSessionID},
+		if err := tx.Scopes(
+			models.MemberProjectResources(userSessionID),
+		).Clauses(
+			clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: clause.CurrentTable}},
+		).Where(
+			&models.Environment{ID: parsedID},
 		).First(&environment).Error; err != nil {
-			return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.DeleteEnvironmentNonExistentID))
+			return rollback(c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.DeleteEnvironmentNonExistentID)))
+		}
+
+		if _, ok := models.AuthorizeProject(tx, userSessionID, environment.ProjectID, utils.ManageEnvironments); !ok {
+			return rollback(c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied)))
+		}
+
+		if !utils.IfMatch(c, environment.Version) {
+			return rollback(c.Status(fiber.StatusPreconditionFailed).JSON(utils.JSONError(utils.PreconditionFailed)))
 		}
 
 		var secrets []models.Secret
+		var removedSecrets []models.Secret
 		if err := tx.Preload("Environments").Not("id", parsedID).Find(
-			&secrets, "user_id=?", userSessionID,
+			&secrets, "project_id=?", environment.ProjectID,
 		).Error; err != nil || len(secrets) > 0 {
 			if err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
 			}
 
+			// a secret that other environments still use only loses this environment
 			for _, secret := range secrets {
 				for _, env := range secret.Environments {
-					if env.ID == environment.ID {
-						tx.Delete(&secret)
+					if env.ID != environment.ID {
+						continue
 					}
+
+					if len(secret.Environments) == 1 {
+						if err := models.CreateSecretDeletionRevision(tx, &secret); err != nil {
+							return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+						}
+
+						if err := tx.Delete(&secret).Error; err != nil {
+							return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+						}
+					}
+
+					removedSecrets = append(removedSecrets, secret)
 				}
 			}
 		}
 
 		if err := tx.Delete(&environment).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := recordAuditEvent(c, tx, models.AuditEvent{
+			Action:      models.AuditEnvironmentDelete,
+			ProjectID:   &environment.ProjectID,
+			ResourceIDs: models.AuditResources(environment.ID),
+		}); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := publishSecretEvent(
+			tx, events.EnvironmentDelete, environment.ProjectID, []uuid.UUID{environment.ID}, removedSecrets...,
+		); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
 		}
 
 		return c.Status(fiber.StatusOK).SendString(
@@ -199,32 +284,322 @@ func UpdateEnvironment(c *fiber.Ctx) error {
 	projectID := utils.MustParseUUID(data.ProjectID)
 
 	var project models.Project
-	if err := db.Where(
-		&models.Project{ID: projectID, UserID: userSessionID},
+	if err := db.Scopes(
+		models.MemberProjects(userSessionID),
+	).Where(
+		&models.Project{ID: projectID},
 	).First(&project).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.UpdateEnvironmentInvalidProjectID))
 	}
 
+	if _, ok := models.Authorize(db, userSessionID, project.OrganizationID, utils.ManageEnvironments); !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
 	envID := utils.MustParseUUID(data.ID)
 
 	if err := db.Not(
 		"id", envID,
 	).Where(
-		&models.Environment{Name: data.UpdatedName, ProjectID: project.ID, UserID: userSessionID},
+		&models.Environment{Name: data.UpdatedName, ProjectID: project.ID},
 	).First(&models.Environment{}).Error; err == nil {
 		return c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.UpdateEnvironmentNameTaken))
 	}
 
 	var environment models.Environment
 	if err := db.Where(
-		&models.Environment{ID: envID, ProjectID: projectID, UserID: userSessionID},
+		&models.Environment{ID: envID, ProjectID: project.ID},
 	).First(&environment).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.UpdateEnvironmentNonExistentID))
 	}
 
-	if err := db.Model(&environment).Update("name", data.UpdatedName).Error; err != nil {
+	if !utils.IfMatch(c, environment.Version) {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(utils.JSONError(utils.PreconditionFailed))
+	}
+
+	if err := models.UpdateVersioned(
+		db, &environment, &environment.Version, map[string]interface{}{"name": data.UpdatedName},
+	); errors.Is(err, gorm.ErrDuplicatedKey) {
+		return c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.UpdateEnvironmentNameTaken))
+	} else if errors.Is(err, models.ErrStaleVersion) {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(utils.JSONError(utils.PreconditionFailed))
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:      models.AuditEnvironmentUpdate,
+		ProjectID:   &project.ID,
+		ResourceIDs: models.AuditResources(environment.ID),
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	utils.SetETag(c, environment.Version)
+
+	return c.Status(fiber.StatusOK).JSON(environment)
+}
+
+func CloneEnvironment(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	var data models.ReqCloneEnv
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.CloneEnvironmentInvalidBody))
+	}
+
+	if err := utils.Validate().Struct(data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.CloneEnvironmentInvalidBody))
+	}
+
+	var environment models.Environment
+	if err := db.Scopes(
+		models.MemberProjectResources(userSessionID),
+	).Where(
+		&models.Environment{ID: utils.MustParseUUID(data.ID)},
+	).First(&environment).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.CloneEnvironmentNonExistentID))
+	}
+
+	member, ok := models.AuthorizeProject(db, userSessionID, environment.ProjectID, utils.ManageEnvironments)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	if !member.CanReadEnvironmentSecrets(db, &environment) {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.EnvironmentPermissionDenied))
+	}
+
+	var environmentCount int64
+	db.Model(&models.Environment{}).Where("project_id=?", environment.ProjectID).Count(&environmentCount)
+	if environmentCount >= models.EnvironmentLimit {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.CloneEnvironmentOverLimit))
+	}
+
+	if err := db.Where(
+		&models.Environment{Name: data.Name, ProjectID: environment.ProjectID},
+	).First(&models.Environment{}).Error; err == nil {
+		return c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.CloneEnvironmentNameTaken))
+	}
+
+	// the clone shares the environment's secrets, parent and restrictions
+	return transaction(db, func(tx *gorm.DB) error {
+		newEnv := models.Environment{
+			Name:      data.Name,
+			ProjectID: environment.ProjectID,
+			UserID:    userSessionID,
+			ParentID:  environment.ParentID,
+		}
+		if err := tx.Create(&newEnv).Error; errors.Is(err, gorm.ErrDuplicatedKey) {
+			return rollback(c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.CloneEnvironmentNameTaken)))
+		} else if err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := tx.Exec(
+			"INSERT INTO environment_secrets (environment_id, secret_id) SELECT ?, secret_id FROM environment_secrets WHERE environment_id = ?",
+			newEnv.ID, environment.ID,
+		).Error; err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		project, err := models.GetSecretProject(tx, environment.ProjectID)
+		if err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := models.CopyEnvironmentSecretValues(tx, &project, environment.ID, newEnv.ID); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		var restrictions []models.EnvironmentRestriction
+		if err := tx.Where(
+			&models.EnvironmentRestriction{EnvironmentID: environment.ID},
+		).Find(&restrictions).Error; err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		for _, restriction := range restrictions {
+			if err := tx.Create(&models.EnvironmentRestriction{
+				EnvironmentID: newEnv.ID,
+				Role:          restriction.Role,
+				Access:        restriction.Access,
+			}).Error; err != nil {
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+			}
+		}
+
+		if err := recordAuditEvent(c, tx, models.AuditEvent{
+			Action:      models.AuditEnvironmentClone,
+			ProjectID:   &environment.ProjectID,
+			ResourceIDs: models.AuditResources(newEnv.ID, environment.ID),
+		}); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(newEnv)
+	})
+}
+
+func UpdateEnvironmentParent(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	var data models.ReqUpdateEnvParent
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateEnvironmentParentInvalidBody))
+	}
+
+	if err := utils.Validate().Struct(data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateEnvironmentParentInvalidBody))
+	}
+
+	var environment models.Environment
+	if err := db.Scopes(
+		models.MemberProjectResources(userSessionID),
+	).Where(
+		&models.Environment{ID: utils.MustParseUUID(data.ID)},
+	).First(&environment).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.UpdateEnvironmentParentNonExistentID))
+	}
+
+	if _, ok := models.AuthorizeProject(db, userSessionID, environment.ProjectID, utils.ManageEnvironments); !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	if !utils.IfMatch(c, environment.Version) {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(utils.JSONError(utils.PreconditionFailed))
+	}
+
+	resourceIDs := []uuid.UUID{environment.ID}
+	var parentID *uuid.UUID
+	if len(data.ParentID) > 0 {
+		var parent models.Environment
+		if err := db.Where(
+			&models.Environment{ID: utils.MustParseUUID(data.ParentID), ProjectID: environment.ProjectID},
+		).First(&parent).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.UpdateEnvironmentParentNonExistentParent))
+		}
+
+		isAncestor, err := models.IsEnvironmentAncestor(db, &environment, &parent)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+		}
+
+		if isAncestor {
+			return c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.UpdateEnvironmentParentCycle))
+		}
+
+		parentID = &parent.ID
+		resourceIDs = append(resourceIDs, parent.ID)
+	}
+
+	if err := models.UpdateVersioned(
+		db, &environment, &environment.Version, map[string]interface{}{"parent_id": parentID},
+	); errors.Is(err, models.ErrStaleVersion) {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(utils.JSONError(utils.PreconditionFailed))
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:      models.AuditEnvironmentUpdate,
+		ProjectID:   &environment.ProjectID,
+		ResourceIDs: models.AuditResources(resourceIDs...),
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
 
+	utils.SetETag(c, environment.Version)
+
 	return c.Status(fiber.StatusOK).JSON(environment)
 }
+
+func GetEnvironmentRestrictions(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	id := c.Params("id")
+	if err := utils.Validate().Var(id, "required,uuid"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.GetEnvironmentRestrictionsInvalidID))
+	}
+
+	var environment models.Environment
+	if err := db.Scopes(
+		models.MemberProjectResources(userSessionID),
+	).Where(
+		&models.Environment{ID: utils.MustParseUUID(id)},
+	).First(&environment).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.GetEnvironmentRestrictionsNonExistentID))
+	}
+
+	var restrictions []models.EnvironmentRestriction
+	db.Where(&models.EnvironmentRestriction{EnvironmentID: environment.ID}).Find(&restrictions)
+
+	return c.Status(fiber.StatusOK).JSON(restrictions)
+}
+
+func UpdateEnvironmentRestriction(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	var data models.ReqUpdateEnvironmentRestriction
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateEnvironmentRestrictionInvalidBody))
+	}
+
+	if err := utils.Validate().Struct(data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateEnvironmentRestrictionInvalidBody))
+	}
+
+	var environment models.Environment
+	if err := db.Scopes(
+		models.MemberProjectResources(userSessionID),
+	).Where(
+		&models.Environment{ID: utils.MustParseUUID(data.EnvironmentID)},
+	).First(&environment).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.UpdateEnvironmentRestrictionNonExistentEnv))
+	}
+
+	if _, ok := models.AuthorizeProject(db, userSessionID, environment.ProjectID, utils.ManageEnvironments); !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	restriction := models.EnvironmentRestriction{EnvironmentID: environment.ID, Role: data.Role}
+
+	// write access lifts the restriction
+	if data.Access == utils.AccessWrite {
+		if err := db.Where(&restriction).Delete(&models.EnvironmentRestriction{}).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+		}
+
+		restriction.Access = utils.DefaultEnvironmentAccess(data.Role)
+
+		if err := recordAuditEvent(c, db, models.AuditEvent{
+			Action:      models.AuditEnvironmentRestrict,
+			ProjectID:   &environment.ProjectID,
+			ResourceIDs: models.AuditResources(environment.ID),
+		}); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+		}
+
+		return c.Status(fiber.StatusOK).JSON(restriction)
+	}
+
+	if err := db.Where(&restriction).Assign(
+		&models.EnvironmentRestriction{Access: data.Access},
+	).FirstOrCreate(&restriction).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:      models.AuditEnvironmentRestrict,
+		ProjectID:   &environment.ProjectID,
+		ResourceIDs: models.AuditResources(environment.ID),
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(restriction)
+}
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mattcarlotta/nvi-api/database"
+	"github.com/mattcarlotta/nvi-api/events"
+	"github.com/mattcarlotta/nvi-api/models"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"github.com/valyala/fasthttp"
+	"gorm.io/gorm"
+)
+
+// how often idle streams are kept alive and their access is checked again
+const eventStreamKeepAlive = 20 * time.Second
+
+// within a transaction, the event is only published once it commits
+func publishSecretEvent(db *gorm.DB, eventType string, projectID uuid.UUID, environmentIDs []uuid.UUID, secrets ...models.Secret) error {
+	versions := make([]events.SecretVersion, 0, len(secrets))
+	for _, secret := range secrets {
+		versions = append(versions, events.SecretVersion{ID: secret.ID, Key: secret.Key, Version: secret.Version})
+	}
+
+	return events.Publish(db.Session(&gorm.Session{NewDB: true}), events.Event{
+		Type:           eventType,
+		ProjectID:      projectID,
+		EnvironmentIDs: environmentIDs,
+		Secrets:        versions,
+	})
+}
+
+func streamEvents(c *fiber.Ctx, db *gorm.DB, environment *models.Environment, authorized func() bool) error {
+	lineage, err := models.GetEnvironmentLineage(db, environment)
+	if err != nil {
+		return err
+	}
+
+	subscription := events.Subscribe(environment.ProjectID, lineage)
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	// keeps proxies such as nginx from buffering the stream
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer subscription.Close()
+
+		fmt.Fprintf(w, "retry: %d\n\n", (3 * time.Second).Milliseconds())
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(eventStreamKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case event, ok := <-subscription.Events():
+				if !ok {
+					return
+				}
+
+				data, err := json.Marshal(event)
+				if err != nil {
+					return
+				}
+
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			case <-keepAlive.C:
+				if !authorized() {
+					return
+				}
+
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}))
+
+	return nil
+}
+
+func StreamEnvironmentEvents(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	id := c.Params("id")
+	if err := utils.Validate().Var(id, "required,uuid"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.StreamEnvironmentEventsInvalidID))
+	}
+
+	var environment models.Environment
+	if err := db.Scopes(
+		models.MemberProjectResources(userSessionID),
+	).Where(
+		&models.Environment{ID: utils.MustParseUUID(id)},
+	).First(&environment).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.StreamEnvironmentEventsNonExistentID))
+	}
+
+	member, ok := models.AuthorizeProject(db, userSessionID, environment.ProjectID, utils.ReadResources)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	if !member.CanReadEnvironmentSecrets(db, &environment) {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.EnvironmentPermissionDenied))
+	}
+
+	authorized := func() bool {
+		member, ok := models.AuthorizeProject(db, userSessionID, environment.ProjectID, utils.ReadResources)
+		return ok && member.CanReadEnvironmentSecrets(db, &environment)
+	}
+
+	if err := streamEvents(c, db, &environment, authorized); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	return nil
+}
+
+func StreamEventsByAPIKey(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	apiKey := getAPIKey(c)
+
+	projectName := c.Query("project")
+	if err := utils.Validate().Var(projectName, "required,name,lte=255"); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			"a valid project name must be supplied in order to stream events",
+		)
+	}
+
+	project, findErr := findProjectByName(c, db, &apiKey, projectName)
+	if findErr != nil {
+		return c.Status(findErr.Code).SendString(findErr.Message)
+	}
+
+	environmentName := c.Query("environment")
+	if err := utils.Validate().Var(environmentName, "required,name,lte=255"); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(
+			"a valid environment name must be supplied in order to stream events",
+		)
+	}
+
+	var environment models.Environment
+	if err := db.Scopes(apiKey.EnvironmentScope).Where(
+		&models.Environment{Name: environmentName, ProjectID: project.ID},
+	).First(&environment).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).SendString(
+			fmt.Sprintf("unable to locate a '%s' environment within the '%s' project", environmentName, projectName),
+		)
+	}
+
+	if !apiKey.CanReadEnvironmentSecrets(db, &environment) {
+		return c.Status(fiber.StatusForbidden).SendString(
+			fmt.Sprintf("the API key isn't scoped to every environment the '%s' environment inherits from", environmentName),
+		)
+	}
+
+	member, ok := models.AuthorizeProject(db, apiKey.UserID, project.ID, utils.ReadResources)
+	if !ok || !member.CanReadEnvironmentSecrets(db, &environment) {
+		return c.Status(fiber.StatusForbidden).SendString(
+			fmt.Sprintf("you don't have access to the '%s' environment's secrets", environmentName),
+		)
+	}
+
+	// the key may have been revoked or have expired since the stream was opened
+	authorized := func() bool {
+		var key models.APIKey
+		if err := db.First(&key, "id=?", apiKey.ID).Error; err != nil || key.IsRevoked() || key.IsExpired() {
+			return false
+		}
+
+		member, ok := models.AuthorizeProject(db, key.UserID, project.ID, utils.ReadResources)
+		return ok && member.CanReadEnvironmentSecrets(db, &environment) && key.CanReadEnvironmentSecrets(db, &environment)
+	}
+
+	if err := streamEvents(c, db, &environment, authorized); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+	}
+
+	return nil
+}
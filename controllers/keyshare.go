@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"encoding/base64"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mattcarlotta/nvi-api/database"
+	"github.com/mattcarlotta/nvi-api/models"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// shares sealed for the previous public key can no longer be opened, so they're removed
+func UpdatePublicKey(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	var data models.ReqUpdatePublicKey
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdatePublicKeyInvalidBody))
+	}
+
+	if err := utils.Validate().Struct(data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdatePublicKeyInvalidBody))
+	}
+
+	publicKey, err := utils.ParseX25519PublicKey(data.PublicKey)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdatePublicKeyInvalidBody))
+	}
+
+	return transaction(db, func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id=?", userSessionID).Update("public_key", publicKey).Error; err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := tx.Where("user_id=?", userSessionID).Delete(&models.ProjectKeyShare{}).Error; err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := recordAuditEvent(c, tx, models.AuditEvent{
+			Action: models.AuditUserUpdatePublicKey,
+		}); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"publicKey": base64.StdEncoding.EncodeToString(publicKey)})
+	})
+}
+
+func GetProjectPublicKeys(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	id := c.Params("id")
+	if err := utils.Validate().Var(id, "required,uuid"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.GetProjectPublicKeysInvalidID))
+	}
+
+	var project models.Project
+	if err := db.Scopes(
+		models.MemberProjects(userSessionID),
+	).Where(
+		&models.Project{ID: utils.MustParseUUID(id)},
+	).First(&project).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.GetProjectPublicKeysNonExistentProject))
+	}
+
+	var publicKeys []models.MemberPublicKey
+	if err := db.Model(&models.OrganizationMember{}).Select(
+		"organization_members.user_id, users.name, users.public_key, project_key_shares.id IS NOT NULL AS has_key_share",
+	).Joins(
+		"JOIN users ON users.id = organization_members.user_id",
+	).Joins(
+		"LEFT JOIN project_key_shares ON project_key_shares.user_id = organization_members.user_id AND project_key_shares.project_id = ?",
+		project.ID,
+	).Where(
+		"organization_members.organization_id = ?", project.OrganizationID,
+	).Order("users.name").Scan(&publicKeys).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(publicKeys)
+}
+
+func GetProjectKeyShare(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	id := c.Params("id")
+	if err := utils.Validate().Var(id, "required,uuid"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.GetKeyShareInvalidID))
+	}
+
+	var keyShare models.ProjectKeyShare
+	if err := db.Where(
+		"project_id IN (?)", db.Model(&models.Project{}).Select("id").Scopes(models.MemberProjects(userSessionID)),
+	).Where(
+		&models.ProjectKeyShare{ProjectID: utils.MustParseUUID(id), UserID: userSessionID},
+	).First(&keyShare).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.GetKeyShareNonExistentShare))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(keyShare)
+}
+
+// the key must be sealed with the member's current public key
+func UpdateProjectKeyShare(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	var data models.ReqUpdateKeyShare
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateKeyShareInvalidBody))
+	}
+
+	if err := utils.Validate().Struct(data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateKeyShareInvalidBody))
+	}
+
+	encryptedKey, err := base64.StdEncoding.DecodeString(data.EncryptedKey)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateKeyShareInvalidBody))
+	}
+
+	var project models.Project
+	if err := db.Scopes(
+		models.MemberProjects(userSessionID),
+	).Where(
+		&models.Project{ID: utils.MustParseUUID(data.ProjectID)},
+	).First(&project).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.UpdateKeyShareNonExistentProject))
+	}
+
+	if !project.EndToEnd {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateKeyShareNotEndToEnd))
+	}
+
+	if _, ok := models.Authorize(db, userSessionID, project.OrganizationID, utils.ManageMembers); !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	var recipient models.User
+	if err := db.Where(
+		"id IN (?)", db.Model(&models.OrganizationMember{}).Select("user_id").Where("organization_id=?", project.OrganizationID),
+	).Where(
+		&models.User{ID: utils.MustParseUUID(data.UserID)},
+	).Where("public_key IS NOT NULL").First(&recipient).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.UpdateKeyShareNonExistentMember))
+	}
+
+	keyShare := models.ProjectKeyShare{
+		ProjectID:      project.ID,
+		UserID:         recipient.ID,
+		SharedByUserID: userSessionID,
+		EncryptedKey:   encryptedKey,
+	}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "project_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"shared_by_user_id", "encrypted_key", "updated_at"}),
+	}).Create(&keyShare).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:         models.AuditProjectShareKey,
+		OrganizationID: &project.OrganizationID,
+		ProjectID:      &project.ID,
+		ResourceIDs:    models.AuditResources(recipient.ID),
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(keyShare)
+}
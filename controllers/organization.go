@@ -0,0 +1,430 @@
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mattcarlotta/nvi-api/database"
+	"github.com/mattcarlotta/nvi-api/models"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/gorm"
+)
+
+func GetAllOrganizations(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	var organizations []models.Organization
+	db.Scopes(models.MemberOrganizations(userSessionID)).Order("created_at").Find(&organizations)
+
+	return c.Status(fiber.StatusOK).JSON(organizations)
+}
+
+func GetOrganizationByID(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	id := c.Params("id")
+	if err := utils.Validate().Var(id, "required,uuid"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.GetOrganizationInvalidID))
+	}
+
+	var organization models.Organization
+	if err := db.Scopes(
+		models.MemberOrganizations(userSessionID),
+	).Where(
+		&models.Organization{ID: utils.MustParseUUID(id)},
+	).First(&organization).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.GetOrganizationNonExistentID))
+	}
+
+	var members []models.OrganizationMemberResult
+	if err := db.Model(&models.OrganizationMember{}).Select(
+		"organization_members.id, organization_members.user_id, users.name, users.email, organization_members.role, organization_members.created_at",
+	).Joins(
+		"JOIN users ON users.id = organization_members.user_id",
+	).Where(
+		"organization_members.organization_id=?", organization.ID,
+	).Order("organization_members.created_at").Scan(&members).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	var invites []models.OrganizationInvite
+	db.Where(&models.OrganizationInvite{OrganizationID: organization.ID}).Find(&invites)
+
+	return c.Status(fiber.StatusOK).JSON(
+		fiber.Map{
+			"invites":      invites,
+			"members":      members,
+			"organization": organization,
+		},
+	)
+}
+
+func CreateOrganization(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	name := c.Params("name")
+	if err := utils.Validate().Var(name, "required,name,lte=255"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.CreateOrganizationInvalidName))
+	}
+
+	if err := db.Where(
+		&models.Organization{Name: name, OwnerID: userSessionID},
+	).First(&models.Organization{}).Error; err == nil {
+		return c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.CreateOrganizationNameTaken))
+	}
+
+	return transaction(db, func(tx *gorm.DB) error {
+		newOrganization := models.Organization{Name: name, OwnerID: userSessionID}
+		if err := tx.Create(&newOrganization).Error; err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := tx.Create(
+			&models.OrganizationMember{OrganizationID: newOrganization.ID, UserID: userSessionID, Role: utils.RoleOwner},
+		).Error; err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := recordAuditEvent(c, tx, models.AuditEvent{
+			Action:         models.AuditOrganizationCreate,
+			OrganizationID: &newOrganization.ID,
+		}); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(newOrganization)
+	})
+}
+
+func UpdateOrganization(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	var data models.ReqUpdateOrganization
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateOrganizationInvalidBody))
+	}
+
+	if err := utils.Validate().Struct(data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateOrganizationInvalidBody))
+	}
+
+	organizationID := utils.MustParseUUID(data.ID)
+
+	var organization models.Organization
+	if err := db.Scopes(
+		models.MemberOrganizations(userSessionID),
+	).Where(
+		&models.Organization{ID: organizationID},
+	).First(&organization).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.UpdateOrganizationNonExistentID))
+	}
+
+	if _, ok := models.Authorize(db, userSessionID, organization.ID, utils.ManageOrganization); !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	if err := db.Not(
+		"id", organizationID,
+	).Where(
+		&models.Organization{Name: data.UpdatedName, OwnerID: organization.OwnerID},
+	).First(&models.Organization{}).Error; err == nil {
+		return c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.UpdateOrganizationNameTaken))
+	}
+
+	if err := db.Model(&organization).Update("name", data.UpdatedName).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:         models.AuditOrganizationUpdate,
+		OrganizationID: &organization.ID,
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(organization)
+}
+
+func DeleteOrganization(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	id := c.Params("id")
+	if err := utils.Validate().Var(id, "required,uuid"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.DeleteOrganizationInvalidID))
+	}
+
+	var organization models.Organization
+	if err := db.Scopes(
+		models.MemberOrganizations(userSessionID),
+	).Where(
+		&models.Organization{ID: utils.MustParseUUID(id)},
+	).First(&organization).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.DeleteOrganizationNonExistentID))
+	}
+
+	if _, ok := models.Authorize(db, userSessionID, organization.ID, utils.ManageOrganization); !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	if organization.Personal {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.DeleteOrganizationPersonal))
+	}
+
+	if err := db.Delete(&organization).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:         models.AuditOrganizationDelete,
+		OrganizationID: &organization.ID,
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	return c.Status(fiber.StatusOK).SendString(
+		fmt.Sprintf("Successfully removed the %s organization!", organization.Name),
+	)
+}
+
+func InviteMember(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	var data models.ReqInviteMember
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.InviteMemberInvalidBody))
+	}
+
+	if err := utils.Validate().Struct(data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.InviteMemberInvalidBody))
+	}
+
+	var organization models.Organization
+	if err := db.Scopes(
+		models.MemberOrganizations(userSessionID),
+	).Where(
+		&models.Organization{ID: utils.MustParseUUID(data.OrganizationID)},
+	).First(&organization).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.InviteMemberNonExistentOrganization))
+	}
+
+	if _, ok := models.Authorize(db, userSessionID, organization.ID, utils.ManageMembers); !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	var inviter models.User
+	if err := db.Where(&models.User{ID: userSessionID}).First(&inviter).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	role := data.Role
+	if len(role) == 0 {
+		role = utils.RoleDeveloper
+	}
+
+	// the email doubles as the name of an invited user until they have an account
+	inviteeName := data.Email
+	var invitee models.User
+	if err := db.Where(&models.User{Email: data.Email}).First(&invitee).Error; err == nil {
+		inviteeName = invitee.Name
+
+		if err := db.Where(
+			&models.OrganizationMember{OrganizationID: organization.ID, UserID: invitee.ID},
+		).First(&models.OrganizationMember{}).Error; err == nil {
+			return c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.InviteMemberAlreadyMember))
+		}
+	}
+
+	invite := models.OrganizationInvite{OrganizationID: organization.ID, Email: data.Email}
+	if err := db.Where(&invite).Assign(
+		&models.OrganizationInvite{InvitedByID: userSessionID, Role: role},
+	).FirstOrCreate(&invite).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:         models.AuditMemberInvite,
+		OrganizationID: &organization.ID,
+		ResourceIDs:    models.AuditResources(invite.ID),
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	token, _, err := utils.GenerateInviteToken(data.Email, organization.ID.String())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if err = utils.SendOrganizationInviteEmail(inviteeName, data.Email, organization.Name, inviter.Name, token); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	return c.Status(fiber.StatusCreated).SendString(
+		fmt.Sprintf("Successfully invited %s to the %s organization!", data.Email, organization.Name),
+	)
+}
+
+func AcceptInvite(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	claims, err := utils.ValidateInviteToken(c.Query("token"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.AcceptInviteInvalidToken))
+	}
+
+	organizationID, err := utils.ParseUUID(claims.OrganizationID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.AcceptInviteInvalidToken))
+	}
+
+	var user models.User
+	if err := db.Where(&models.User{ID: userSessionID}).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	var invite models.OrganizationInvite
+	if err := db.Where(
+		&models.OrganizationInvite{OrganizationID: organizationID, Email: user.Email},
+	).First(&invite).Error; err != nil || claims.Email != user.Email {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.AcceptInviteNonExistentInvite))
+	}
+
+	var organization models.Organization
+	if err := db.Where(&models.Organization{ID: invite.OrganizationID}).First(&organization).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.AcceptInviteNonExistentInvite))
+	}
+
+	return transaction(db, func(tx *gorm.DB) error {
+		member := models.OrganizationMember{OrganizationID: organization.ID, UserID: user.ID}
+		if err := tx.Where(&member).Attrs(
+			&models.OrganizationMember{Role: invite.Role},
+		).FirstOrCreate(&member).Error; err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := tx.Delete(&invite).Error; err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := recordAuditEvent(c, tx, models.AuditEvent{
+			Action:         models.AuditMemberJoin,
+			OrganizationID: &organization.ID,
+			ResourceIDs:    models.AuditResources(member.ID),
+		}); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(organization)
+	})
+}
+
+func RemoveMember(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	id := c.Params("id")
+	if err := utils.Validate().Var(id, "required,uuid"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.RemoveMemberInvalidID))
+	}
+
+	var member models.OrganizationMember
+	if err := db.Where(&models.OrganizationMember{ID: utils.MustParseUUID(id)}).First(&member).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.RemoveMemberNonExistentID))
+	}
+
+	var organization models.Organization
+	if err := db.Scopes(
+		models.MemberOrganizations(userSessionID),
+	).Where(
+		&models.Organization{ID: member.OrganizationID},
+	).First(&organization).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.RemoveMemberNonExistentID))
+	}
+
+	// members can always leave
+	if member.UserID != userSessionID {
+		if _, ok := models.Authorize(db, userSessionID, organization.ID, utils.ManageMembers); !ok {
+			return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+		}
+	}
+
+	if member.Role == utils.RoleOwner {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.RemoveMemberOwner))
+	}
+
+	if err := db.Delete(&member).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	// the member is no longer served the project keys, but the ones they've opened should be rotated
+	if err := db.Where(
+		"user_id = ? AND project_id IN (?)",
+		member.UserID, db.Model(&models.Project{}).Select("id").Where("organization_id = ?", organization.ID),
+	).Delete(&models.ProjectKeyShare{}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:         models.AuditMemberRemove,
+		OrganizationID: &organization.ID,
+		ResourceIDs:    models.AuditResources(member.ID, member.UserID),
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	return c.Status(fiber.StatusOK).SendString(
+		fmt.Sprintf("Successfully removed the member from the %s organization!", organization.Name),
+	)
+}
+
+func UpdateMemberRole(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	var data models.ReqUpdateMemberRole
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateMemberRoleInvalidBody))
+	}
+
+	if err := utils.Validate().Struct(data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateMemberRoleInvalidBody))
+	}
+
+	var member models.OrganizationMember
+	if err := db.Scopes(
+		models.MemberOrganizationResources(userSessionID),
+	).Where(
+		&models.OrganizationMember{ID: utils.MustParseUUID(data.ID)},
+	).First(&member).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.UpdateMemberRoleNonExistentID))
+	}
+
+	if _, ok := models.Authorize(db, userSessionID, member.OrganizationID, utils.ManageMembers); !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	// this also keeps owners from demoting themselves
+	if member.Role == utils.RoleOwner {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.UpdateMemberRoleOwner))
+	}
+
+	if err := db.Model(&member).Update("role", data.Role).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:         models.AuditMemberUpdateRole,
+		OrganizationID: &member.OrganizationID,
+		ResourceIDs:    models.AuditResources(member.ID, member.UserID),
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(member)
+}
@@ -1,12 +1,14 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/mattcarlotta/nvi-api/database"
 	"github.com/mattcarlotta/nvi-api/models"
 	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/gorm"
 )
 
 func GetAllProjects(c *fiber.Ctx) error {
@@ -14,7 +16,7 @@ func GetAllProjects(c *fiber.Ctx) error {
 	userSessionID := utils.GetSessionID(c)
 
 	var projects []models.Project
-	db.Where(&models.Project{UserID: userSessionID}).Find(&projects)
+	db.Scopes(models.MemberProjects(userSessionID)).Find(&projects)
 
 	return c.Status(fiber.StatusOK).JSON(projects)
 }
@@ -29,12 +31,16 @@ func GetProjectByID(c *fiber.Ctx) error {
 	}
 
 	var project models.Project
-	if err := db.Where(
-		&models.Project{ID: utils.MustParseUUID(id), UserID: userSessionID},
+	if err := db.Scopes(
+		models.MemberProjects(userSessionID),
+	).Where(
+		&models.Project{ID: utils.MustParseUUID(id)},
 	).First(&project).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.GetProjectInvalidID))
 	}
 
+	utils.SetETag(c, project.Version)
+
 	return c.Status(fiber.StatusOK).JSON(project)
 }
 
@@ -48,8 +54,10 @@ func GetProjectByName(c *fiber.Ctx) error {
 	}
 
 	var project models.Project
-	if err := db.Where(
-		&models.Project{Name: name, UserID: userSessionID},
+	if err := db.Scopes(
+		models.MemberProjects(userSessionID), models.InOrganization(c.Query("organizationID")),
+	).Where(
+		&models.Project{Name: name},
 	).First(&project).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.GetProjectNonExistentName))
 	}
@@ -67,8 +75,10 @@ func SearchForProjectsByName(c *fiber.Ctx) error {
 	}
 
 	var projects []models.Project
-	if err := db.Where(
-		"name ILIKE ? AND user_id=?", "%"+name+"%", userSessionID,
+	if err := db.Scopes(
+		models.MemberProjects(userSessionID),
+	).Where(
+		"name ILIKE ?", "%"+name+"%",
 	).Find(&projects).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
@@ -85,21 +95,56 @@ func CreateProject(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.CreateProjectInvalidName))
 	}
 
+	// the user's personal organization is used unless another one is selected
+	organizationQuery := db.Where(&models.Organization{OwnerID: userSessionID, Personal: true})
+	if organizationID := c.Query("organizationID"); len(organizationID) > 0 {
+		if err := utils.Validate().Var(organizationID, "uuid"); err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.CreateProjectNonExistentOrganization))
+		}
+		organizationQuery = db.Scopes(
+			models.MemberOrganizations(userSessionID),
+		).Where(&models.Organization{ID: utils.MustParseUUID(organizationID)})
+	}
+
+	var organization models.Organization
+	if err := organizationQuery.First(&organization).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.CreateProjectNonExistentOrganization))
+	}
+
+	if _, ok := models.Authorize(db, userSessionID, organization.ID, utils.ManageProjects); !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
 	var projectCount int64
-	db.Model(&models.Project{}).Where("user_id=?", userSessionID).Count(&projectCount)
+	db.Model(&models.Project{}).Where("organization_id=?", organization.ID).Count(&projectCount)
 	if projectCount >= 10 {
 		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.CreateProjectOverLimit))
 	}
 
 	var project models.Project
 	if err := db.Where(
-		&models.Project{Name: name, UserID: userSessionID},
+		&models.Project{Name: name, OrganizationID: organization.ID},
 	).First(&project).Error; err == nil {
 		return c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.CreateProjectNameTaken))
 	}
 
-	newProject := models.Project{Name: name, UserID: userSessionID}
-	if err := db.Create(&newProject).Error; err != nil {
+	newProject := models.Project{
+		Name:           name,
+		OrganizationID: organization.ID,
+		UserID:         userSessionID,
+		EndToEnd:       c.QueryBool("endToEnd"),
+	}
+	if err := db.Create(&newProject).Error; errors.Is(err, gorm.ErrDuplicatedKey) {
+		return c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.CreateProjectNameTaken))
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:         models.AuditProjectCreate,
+		OrganizationID: &organization.ID,
+		ProjectID:      &newProject.ID,
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
 
@@ -116,13 +161,33 @@ func DeleteProject(c *fiber.Ctx) error {
 	}
 
 	var project models.Project
-	if err := db.Where(
-		&models.Project{ID: utils.MustParseUUID(id), UserID: userSessionID},
+	if err := db.Scopes(
+		models.MemberProjects(userSessionID),
+	).Where(
+		&models.Project{ID: utils.MustParseUUID(id)},
 	).First(&project).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.DeleteProjectNonExistentID))
 	}
 
-	if err := db.Delete(&project).Error; err != nil {
+	if _, ok := models.Authorize(db, userSessionID, project.OrganizationID, utils.ManageProjects); !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	if !utils.IfMatch(c, project.Version) {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(utils.JSONError(utils.PreconditionFailed))
+	}
+
+	if err := models.DeleteVersioned(db, &project, project.Version); errors.Is(err, models.ErrStaleVersion) {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(utils.JSONError(utils.PreconditionFailed))
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:         models.AuditProjectDelete,
+		OrganizationID: &project.OrganizationID,
+		ProjectID:      &project.ID,
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
 
@@ -147,23 +212,49 @@ func UpdateProject(c *fiber.Ctx) error {
 	projectID := utils.MustParseUUID(data.ID)
 
 	var existingProject models.Project
-	if err := db.Where(
-		&models.Project{ID: projectID, UserID: userSessionID},
+	if err := db.Scopes(
+		models.MemberProjects(userSessionID),
+	).Where(
+		&models.Project{ID: projectID},
 	).First(&existingProject).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.UpdateProjectNonExistentID))
 	}
 
+	if _, ok := models.Authorize(db, userSessionID, existingProject.OrganizationID, utils.ManageProjects); !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	if !utils.IfMatch(c, existingProject.Version) {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(utils.JSONError(utils.PreconditionFailed))
+	}
+
 	if err := db.Not(
 		"id", projectID,
 	).Where(
-		&models.Project{Name: data.UpdatedName, UserID: userSessionID},
+		&models.Project{Name: data.UpdatedName, OrganizationID: existingProject.OrganizationID},
 	).First(&models.Project{}).Error; err == nil {
 		return c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.UpdateProjectNameTaken))
 	}
 
-	if err := db.Model(&existingProject).Update("name", data.UpdatedName).Error; err != nil {
+	if err := models.UpdateVersioned(
+		db, &existingProject, &existingProject.Version, map[string]interface{}{"name": data.UpdatedName},
+	); errors.Is(err, gorm.ErrDuplicatedKey) {
+		return c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.UpdateProjectNameTaken))
+	} else if errors.Is(err, models.ErrStaleVersion) {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(utils.JSONError(utils.PreconditionFailed))
+	} else if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
 
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:         models.AuditProjectUpdate,
+		OrganizationID: &existingProject.OrganizationID,
+		ProjectID:      &existingProject.ID,
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	utils.SetETag(c, existingProject.Version)
+
 	return c.Status(fiber.StatusOK).JSON(existingProject)
 }
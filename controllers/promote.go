@@ -0,0 +1,347 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mattcarlotta/nvi-api/database"
+	"github.com/mattcarlotta/nvi-api/events"
+	"github.com/mattcarlotta/nvi-api/models"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type promotion struct {
+	Target    string   `json:"target"`
+	Exists    bool     `json:"exists"`
+	Additions []string `json:"additions"`
+	Changes   []string `json:"changes"`
+	Removals  []string `json:"removals"`
+	Unchanged []string `json:"unchanged"`
+}
+
+type promotionPlan struct {
+	result        promotion
+	sourceValues  map[string]string
+	targetSecrets map[string]models.Secret
+}
+
+// within a transaction, the target's secrets stay locked until it ends
+func planPromotion(
+	db *gorm.DB, project *models.Project, source *models.Environment, target *models.Environment, exists bool, data models.ReqPromoteEnv,
+) (promotionPlan, error) {
+	plan := promotionPlan{
+		result: promotion{
+			Target:    data.Target,
+			Exists:    exists,
+			Additions: []string{},
+			Changes:   []string{},
+			Removals:  []string{},
+			Unchanged: []string{},
+		},
+		sourceValues:  make(map[string]string),
+		targetSecrets: make(map[string]models.Secret),
+	}
+
+	var sourceSecrets []models.SecretResult
+	if err := db.Raw(
+		utils.FindSecretsByEnvIDQuery, source.ProjectID, source.ID,
+	).Scan(&sourceSecrets).Error; err != nil {
+		return plan, err
+	}
+
+	for _, secret := range sourceSecrets {
+		value, err := project.OpenSecretResult(&secret)
+		if err != nil {
+			return plan, err
+		}
+		plan.sourceValues[secret.Key] = value
+	}
+
+	targetValues := make(map[string]string)
+	if exists {
+		var secrets []models.Secret
+		if err := db.Clauses(
+			clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: clause.CurrentTable}},
+		).Preload("Environments").Joins(
+			"JOIN environment_secrets es ON es.secret_id = secrets.id",
+		).Where("es.environment_id = ?", target.ID).Find(&secrets).Error; err != nil {
+			return plan, err
+		}
+
+		for _, secret := range secrets {
+			plan.targetSecrets[secret.Key] = secret
+		}
+
+		var results []models.SecretResult
+		if err := db.Raw(
+			utils.FindOwnSecretsByEnvIDQuery, target.ProjectID, target.ID,
+		).Scan(&results).Error; err != nil {
+			return plan, err
+		}
+
+		for _, secret := range results {
+			value, err := project.OpenSecretResult(&secret)
+			if err != nil {
+				return plan, err
+			}
+			targetValues[secret.Key] = value
+		}
+	}
+
+	for _, key := range data.Keys {
+		value, inSource := plan.sourceValues[key]
+		targetValue, inTarget := targetValues[key]
+
+		switch {
+		case inSource && !inTarget:
+			plan.result.Additions = append(plan.result.Additions, key)
+		case !inSource && inTarget:
+			plan.result.Removals = append(plan.result.Removals, key)
+		case inSource && inTarget:
+			if targetValue == value {
+				plan.result.Unchanged = append(plan.result.Unchanged, key)
+			} else {
+				plan.result.Changes = append(plan.result.Changes, key)
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// when "preview" is true, the changes and the ETag to apply them with are returned without being applied
+func PromoteEnvironment(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	var data models.ReqPromoteEnv
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.PromoteEnvironmentInvalidBody))
+	}
+
+	if err := utils.Validate().Struct(data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.PromoteEnvironmentInvalidBody))
+	}
+
+	var source models.Environment
+	if err := db.Scopes(
+		models.MemberProjectResources(userSessionID),
+	).Where(
+		&models.Environment{ID: utils.MustParseUUID(data.SourceID)},
+	).First(&source).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.PromoteEnvironmentNonExistentID))
+	}
+
+	if source.Name == data.Target {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.PromoteEnvironmentSameEnvironment))
+	}
+
+	member, ok := models.AuthorizeProject(db, userSessionID, source.ProjectID, utils.WriteSecrets)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	if !member.CanReadEnvironmentSecrets(db, &source) {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.EnvironmentPermissionDenied))
+	}
+
+	var target models.Environment
+	exists := db.Where(
+		&models.Environment{Name: data.Target, ProjectID: source.ProjectID},
+	).First(&target).Error == nil
+
+	if exists {
+		if !member.CanAccessEnvironments(db, []uuid.UUID{target.ID}, utils.AccessWrite) {
+			return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.EnvironmentPermissionDenied))
+		}
+	} else {
+		if !utils.RoleHasPermission(member.Role, utils.ManageEnvironments) {
+			return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+		}
+
+		var environmentCount int64
+		db.Model(&models.Environment{}).Where("project_id=?", source.ProjectID).Count(&environmentCount)
+		if environmentCount >= models.EnvironmentLimit {
+			return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PromoteEnvironmentOverLimit))
+		}
+	}
+
+	project, err := models.GetSecretProject(db, source.ProjectID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if c.QueryBool("preview") {
+		// the ETag is read before the plan is made, so that a change in between makes it stale
+		if exists {
+			etag, err := models.GetEnvironmentSecretsETag(db, &target)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+			}
+			c.Set(fiber.HeaderETag, etag)
+		}
+
+		plan, err := planPromotion(db, &project, &source, &target, exists, data)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+		}
+
+		return c.Status(fiber.StatusOK).JSON(plan.result)
+	}
+
+	return transaction(db, func(tx *gorm.DB) error {
+		if exists {
+			if err := tx.Clauses(
+				clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: clause.CurrentTable}},
+			).First(&target, "id=?", target.ID).Error; err != nil {
+				return rollback(c.Status(fiber.StatusPreconditionFailed).JSON(utils.JSONError(utils.PreconditionFailed)))
+			}
+
+			if ifMatch := c.Get(fiber.HeaderIfMatch); len(ifMatch) > 0 {
+				etag, err := models.GetEnvironmentSecretsETag(tx, &target)
+				if err != nil {
+					return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+				}
+
+				if !utils.MatchesETag(ifMatch, etag) {
+					return rollback(c.Status(fiber.StatusPreconditionFailed).JSON(utils.JSONError(utils.PreconditionFailed)))
+				}
+			}
+		} else {
+			target = models.Environment{Name: data.Target, ProjectID: source.ProjectID, UserID: userSessionID}
+			if err := tx.Create(&target).Error; errors.Is(err, gorm.ErrDuplicatedKey) {
+				return rollback(c.Status(fiber.StatusPreconditionFailed).JSON(utils.JSONError(utils.PreconditionFailed)))
+			} else if err != nil {
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+			}
+		}
+
+		plan, err := planPromotion(tx, &project, &source, &target, exists, data)
+		if err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+		result, sourceValues, targetSecrets := plan.result, plan.sourceValues, plan.targetSecrets
+
+		resourceIDs := []uuid.UUID{source.ID, target.ID}
+		var createdSecrets, changedSecrets, removedSecrets []models.Secret
+
+		// a secret shared with other environments is detached or given a value within the target instead
+		detach := func(secret models.Secret) error {
+			if len(secret.Environments) > 1 {
+				return tx.Model(&secret).Association("Environments").Delete(&target)
+			}
+
+			if err := models.CreateSecretDeletionRevision(tx, &secret); err != nil {
+				return err
+			}
+			return tx.Delete(&secret).Error
+		}
+
+		create := func(key string) error {
+			value, err := project.ParseSecretValue(sourceValues[key])
+			if err != nil {
+				return err
+			}
+
+			newSecret := models.Secret{
+				Key:          key,
+				Value:        value,
+				ProjectID:    source.ProjectID,
+				UserID:       userSessionID,
+				Environments: []models.Environment{target},
+			}
+			if err := tx.Create(&newSecret).Error; err != nil {
+				return err
+			}
+
+			resourceIDs = append(resourceIDs, newSecret.ID)
+			createdSecrets = append(createdSecrets, newSecret)
+			return nil
+		}
+
+		for _, key := range result.Additions {
+			if err := create(key); err != nil {
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+			}
+		}
+
+		for _, key := range result.Changes {
+			secret := targetSecrets[key]
+			value, err := project.ParseSecretValue(sourceValues[key])
+			if err != nil {
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+			}
+
+			if len(secret.Environments) > 1 {
+				if err := models.SetEnvironmentSecretValue(tx, &project, secret.ID, target.ID, value); err != nil {
+					return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+				}
+			} else {
+				newValue, newNonce, sealVersion, err := project.SealSecretValue(secret.ID, secret.Key, value)
+				if err != nil {
+					return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+				}
+
+				if err := tx.Model(&secret).Updates(
+					&models.Secret{Value: newValue, Nonce: newNonce, SealVersion: sealVersion},
+				).Error; err != nil {
+					return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+				}
+
+				if err := models.ClearEnvironmentSecretValues(tx, secret.ID, []uuid.UUID{target.ID}); err != nil {
+					return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+				}
+			}
+
+			if err := models.CreateSecretRevision(tx, &secret); err != nil {
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+			}
+
+			resourceIDs = append(resourceIDs, secret.ID)
+			changedSecrets = append(changedSecrets, secret)
+		}
+
+		for _, key := range result.Removals {
+			secret := targetSecrets[key]
+			if err := detach(secret); err != nil {
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+			}
+
+			resourceIDs = append(resourceIDs, secret.ID)
+			removedSecrets = append(removedSecrets, secret)
+		}
+
+		if err := recordAuditEvent(c, tx, models.AuditEvent{
+			Action:      models.AuditEnvironmentPromote,
+			ProjectID:   &source.ProjectID,
+			ResourceIDs: models.AuditResources(resourceIDs...),
+		}); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		for _, change := range []struct {
+			eventType string
+			secrets   []models.Secret
+		}{
+			{events.SecretCreate, createdSecrets},
+			{events.SecretUpdate, changedSecrets},
+			{events.SecretDelete, removedSecrets},
+		} {
+			if len(change.secrets) == 0 {
+				continue
+			}
+
+			if err := publishSecretEvent(
+				tx, change.eventType, source.ProjectID, []uuid.UUID{target.ID}, change.secrets...,
+			); err != nil {
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+			}
+		}
+
+		result.Exists = true
+		return c.Status(fiber.StatusOK).JSON(result)
+	})
+}
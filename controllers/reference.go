@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mattcarlotta/nvi-api/models"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/gorm"
+)
+
+func openSecrets(project *models.Project, secrets []models.SecretResult) (utils.EnvVars, error) {
+	envVars := make(utils.EnvVars, 0, len(secrets))
+	for _, secret := range secrets {
+		value, err := project.OpenSecretResult(&secret)
+		if err != nil {
+			return nil, err
+		}
+		envVars = append(envVars, utils.EnvVar{Key: secret.Key, Value: value})
+	}
+	return envVars, nil
+}
+
+type loadedEnvironment struct {
+	projectID     uuid.UUID
+	environmentID uuid.UUID
+	secretIDs     map[string]uuid.UUID
+}
+
+type loadedEnvironments map[[2]string]loadedEnvironment
+
+// a project is looked up within the referencing project's organization first; environments that can't be read
+// are reported as dangling references
+func secretEnvironmentLoader(
+	db *gorm.DB, userID uuid.UUID, organizationID uuid.UUID, apiKey *models.APIKey, loaded loadedEnvironments,
+) utils.SecretEnvironmentLoader {
+	return func(projectName string, environmentName string) (utils.EnvVars, error) {
+		projectQuery := db.Scopes(models.MemberProjects(userID)).Where(&models.Project{Name: projectName})
+		environmentQuery := db.Model(&models.Environment{})
+		if apiKey != nil {
+			projectQuery = projectQuery.Scopes(apiKey.ProjectScope)
+			environmentQuery = environmentQuery.Scopes(apiKey.EnvironmentScope)
+		}
+
+		var projects []models.Project
+		if err := projectQuery.Find(&projects).Error; err != nil {
+			return nil, err
+		}
+
+		var project *models.Project
+		for i := range projects {
+			if projects[i].OrganizationID == organizationID {
+				project = &projects[i]
+				break
+			}
+		}
+		if project == nil && len(projects) == 1 {
+			project = &projects[0]
+		}
+		if project == nil || project.EndToEnd {
+			return nil, nil
+		}
+
+		var environment models.Environment
+		if err := environmentQuery.Where(
+			&models.Environment{Name: environmentName, ProjectID: project.ID},
+		).First(&environment).Error; err != nil {
+			return nil, nil
+		}
+
+		if apiKey != nil && !apiKey.CanReadEnvironmentSecrets(db, &environment) {
+			return nil, nil
+		}
+
+		member, ok := models.AuthorizeProject(db, userID, project.ID, utils.ReadResources)
+		if !ok || !member.CanReadEnvironmentSecrets(db, &environment) {
+			return nil, nil
+		}
+
+		var secrets []models.SecretResult
+		if err := db.Raw(
+			utils.FindSecretsByEnvIDQuery, project.ID, environment.ID,
+		).Scan(&secrets).Error; err != nil {
+			return nil, err
+		}
+
+		secretIDs := make(map[string]uuid.UUID, len(secrets))
+		for _, secret := range secrets {
+			secretIDs[secret.Key] = secret.ID
+		}
+		loaded[[2]string{projectName, environmentName}] = loadedEnvironment{
+			projectID: project.ID, environmentID: environment.ID, secretIDs: secretIDs,
+		}
+
+		return openSecrets(project, secrets)
+	}
+}
+
+// a referenced secret from another project is also audited within that project's chain
+func recordReferencedSecretReads(
+	c *fiber.Ctx, db *gorm.DB, action string, projectID uuid.UUID, resolver *utils.SecretResolver, loaded loadedEnvironments,
+) ([]uuid.UUID, error) {
+	var referencedIDs []uuid.UUID
+	var names [][2]string
+	readIDs := make(map[[2]string][]uuid.UUID)
+	for _, reference := range resolver.ReferencedSecrets() {
+		name := [2]string{reference.Project, reference.Environment}
+		id, ok := loaded[name].secretIDs[reference.Key]
+		if !ok {
+			continue
+		}
+
+		if _, ok := readIDs[name]; !ok {
+			names = append(names, name)
+		}
+		readIDs[name] = append(readIDs[name], id)
+		referencedIDs = append(referencedIDs, id)
+	}
+
+	for _, name := range names {
+		environment := loaded[name]
+		if environment.projectID == projectID {
+			continue
+		}
+
+		if err := recordAuditEvent(c, db, models.AuditEvent{
+			Action:      action,
+			ProjectID:   &environment.projectID,
+			ResourceIDs: models.AuditResources(append(readIDs[name], environment.environmentID)...),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return referencedIDs, nil
+}
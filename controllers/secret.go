@@ -1,14 +1,17 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/mattcarlotta/nvi-api/database"
+	"github.com/mattcarlotta/nvi-api/events"
 	"github.com/mattcarlotta/nvi-api/models"
 	"github.com/mattcarlotta/nvi-api/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func GetSecretsByProjectAndEnvironmentName(c *fiber.Ctx) error {
@@ -26,28 +29,81 @@ func GetSecretsByProjectAndEnvironmentName(c *fiber.Ctx) error {
 	}
 
 	var project models.Project
-	if err := db.Where(
-		&models.Project{Name: projectName, UserID: userSessionID},
+	if err := db.Scopes(
+		models.MemberProjects(userSessionID), models.InOrganization(c.Query("organizationID")),
+	).Where(
+		&models.Project{Name: projectName},
 	).First(&project).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.GetProjectNonExistentName))
 	}
 
 	var environment models.Environment
 	if err := db.Where(
-		&models.Environment{Name: environmentName, ProjectID: project.ID, UserID: userSessionID},
+		&models.Environment{Name: environmentName, ProjectID: project.ID},
 	).First(&environment).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.GetEnvironmentNonExistentName))
 	}
 
+	member, ok := models.Authorize(db, userSessionID, project.OrganizationID, utils.ReadResources)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	if !member.CanReadEnvironmentSecrets(db, &environment) {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.EnvironmentPermissionDenied))
+	}
+
 	var secrets []models.SecretResult
 	if err := db.Raw(
-		utils.FindSecretsByEnvIDQuery, userSessionID, utils.GenerateJSONIDString(environment.ID),
+		utils.FindSecretsByEnvIDQuery, project.ID, environment.ID,
 	).Scan(&secrets).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
 
+	// end-to-end encrypted values can't be resolved by the server
+	if !project.EndToEnd {
+		envVars, err := openSecrets(&project, secrets)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+		}
+
+		loaded := make(loadedEnvironments)
+		resolver := utils.NewSecretResolver(secretEnvironmentLoader(db, userSessionID, project.OrganizationID, nil, loaded))
+		resolvedVars, err := resolver.Resolve(project.Name, environment.Name, envVars)
+		if err != nil {
+			if code, ok := utils.SecretReferenceErrorCode(err); ok {
+				return c.Status(fiber.StatusUnprocessableEntity).JSON(utils.JSONError(code))
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+		}
+
+		var resolvedIDs []uuid.UUID
+		for i := range secrets {
+			if utils.HasSecretReferences(envVars[i].Value) {
+				secrets[i].ResolvedValue = &resolvedVars[i].Value
+				resolvedIDs = append(resolvedIDs, secrets[i].ID)
+			}
+		}
+
+		referencedIDs, err := recordReferencedSecretReads(c, db, models.AuditSecretRead, project.ID, resolver, loaded)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+		}
+
+		if len(resolvedIDs) > 0 {
+			resolvedIDs = append(resolvedIDs, referencedIDs...)
+			if err := recordAuditEvent(c, db, models.AuditEvent{
+				Action:      models.AuditSecretRead,
+				ProjectID:   &project.ID,
+				ResourceIDs: models.AuditResources(append(resolvedIDs, environment.ID)...),
+			}); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+			}
+		}
+	}
+
 	var environments []models.Environment
-	db.Where(&models.Environment{UserID: userSessionID, ProjectID: project.ID}).Find(&environments)
+	db.Where(&models.Environment{ProjectID: project.ID}).Find(&environments)
 
 	return c.Status(fiber.StatusOK).JSON(
 		fiber.Map{
@@ -69,13 +125,40 @@ func GetSecretBySecretID(c *fiber.Ctx) error {
 	}
 
 	var secret models.Secret
-	if err := db.Preload("Environments").First(
-		&secret, "id=? AND user_id=?", utils.MustParseUUID(id), userSessionID,
+	if err := db.Scopes(
+		models.MemberProjectResources(userSessionID),
+	).Preload("Environments").First(
+		&secret, "id=?", utils.MustParseUUID(id),
 	).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.GetSecretNonExistentID))
 	}
 
-	decryptedValue, err := utils.DecryptSecretValue(secret.Value, secret.Nonce)
+	member, ok := models.AuthorizeProject(db, userSessionID, secret.ProjectID, utils.ReadResources)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	// a shared value can be read through any environment the member can read
+	if !member.CanAccessAnyEnvironment(db, models.GetEnvIDs(&secret.Environments), utils.AccessRead) {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.EnvironmentPermissionDenied))
+	}
+
+	project, err := models.GetSecretProject(db, secret.ProjectID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	value, err := project.OpenSecretValue(secret.ID, secret.Key, secret.SealVersion, secret.Value, secret.Nonce)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	values, err := models.GetEnvironmentSecretValues(db, secret.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	environmentValues, err := member.OpenEnvironmentSecretValues(db, &project, secret.ID, values)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
@@ -85,8 +168,25 @@ func GetSecretBySecretID(c *fiber.Ctx) error {
 		environmentIDs = append(environmentIDs, s.ID)
 	}
 
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:      models.AuditSecretRead,
+		ProjectID:   &secret.ProjectID,
+		ResourceIDs: models.AuditResources(append([]uuid.UUID{secret.ID}, environmentIDs...)...),
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	utils.SetETag(c, secret.Version)
+
 	return c.Status(fiber.StatusOK).JSON(
-		fiber.Map{"environmentIDs": environmentIDs, "key": secret.Key, "value": string(decryptedValue)},
+		fiber.Map{
+			"environmentIDs":    environmentIDs,
+			"environmentValues": environmentValues,
+			"key":               secret.Key,
+			"value":             value,
+			"version":           secret.Version,
+			"endToEnd":          project.EndToEnd,
+		},
 	)
 }
 
@@ -102,13 +202,26 @@ func GetSecretsByEnvironmentID(c *fiber.Ctx) error {
 	parsedEnvID := utils.MustParseUUID(id)
 
 	var environment models.Environment
-	if err := db.Where(&models.Environment{ID: parsedEnvID}).First(&environment).Error; err != nil {
+	if err := db.Scopes(
+		models.MemberProjectResources(userSessionID),
+	).Where(
+		&models.Environment{ID: parsedEnvID},
+	).First(&environment).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.GetSecretsByEnvNonExistentID))
 	}
 
+	member, ok := models.AuthorizeProject(db, userSessionID, environment.ProjectID, utils.ReadResources)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	if !member.CanReadEnvironmentSecrets(db, &environment) {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.EnvironmentPermissionDenied))
+	}
+
 	var secrets []models.SecretResult
 	if err := db.Raw(
-		utils.FindSecretsByEnvIDQuery, userSessionID, utils.GenerateJSONIDString(environment.ID),
+		utils.FindSecretsByEnvIDQuery, environment.ProjectID, environment.ID,
 	).Scan(&secrets).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
@@ -130,14 +243,31 @@ func SearchForSecretsByEnvironmentIDAndSecretKey(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.GetEnvironmentInvalidID))
 	}
 
-	parsedEnvID := utils.MustParseUUID(environmentID)
-
 	var secrets []models.SecretResult
+
+	var environment models.Environment
+	if err := db.Scopes(
+		models.MemberProjectResources(userSessionID),
+	).Where(
+		&models.Environment{ID: utils.MustParseUUID(environmentID)},
+	).First(&environment).Error; err != nil {
+		return c.Status(fiber.StatusOK).JSON(secrets)
+	}
+
+	member, ok := models.AuthorizeProject(db, userSessionID, environment.ProjectID, utils.ReadResources)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	if !member.CanReadEnvironmentSecrets(db, &environment) {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.EnvironmentPermissionDenied))
+	}
+
 	if err := db.Raw(
 		utils.FindSecretsByEnvIDAndSecretKeyQuery,
-		userSessionID,
+		environment.ProjectID,
+		environment.ID,
 		"%"+key+"%",
-		utils.GenerateJSONIDString(parsedEnvID),
 	).Scan(&secrets).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
@@ -161,8 +291,10 @@ func CreateSecret(c *fiber.Ctx) error {
 	projectID := utils.MustParseUUID(data.ProjectID)
 
 	var project models.Project
-	if err := db.Where(
-		&models.Project{ID: projectID, UserID: userSessionID},
+	if err := db.Scopes(
+		models.MemberProjects(userSessionID),
+	).Where(
+		&models.Project{ID: projectID},
 	).First(&project).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.CreateSecretNonExistentProject))
 	}
@@ -174,11 +306,20 @@ func CreateSecret(c *fiber.Ctx) error {
 
 	var environments []models.Environment
 	if err := db.Find(
-		&environments, "id IN ? AND project_id=? AND user_id=?", environmentIDs, projectID, userSessionID,
+		&environments, "id IN ? AND project_id=?", environmentIDs, project.ID,
 	).Error; err != nil || len(environments) == 0 {
 		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.CreateSecretNonExistentEnv))
 	}
 
+	member, ok := models.Authorize(db, userSessionID, project.OrganizationID, utils.WriteSecrets)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	if !member.CanAccessEnvironments(db, models.GetEnvIDs(&environments), utils.AccessWrite) {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.EnvironmentPermissionDenied))
+	}
+
 	// var secrets []models.SecretResult
 	// if err := db.Raw(
 	//      utils.GenerateFindSecretByEnvIDsQuery, userSessionID, data.Key,
@@ -197,9 +338,9 @@ func CreateSecret(c *fiber.Ctx) error {
 
 	var secrets []models.Secret
 	if err := db.Preload(
-		"Environments", "id in ? AND project_id=?", environmentIDs, projectID,
+		"Environments", "id in ? AND project_id=?", environmentIDs, project.ID,
 	).Find(
-		&secrets, "key=? AND user_id=?", data.Key, userSessionID,
+		&secrets, "key=? AND project_id=?", data.Key, project.ID,
 	).Error; err == nil {
 		duplicates := models.GetDupKeyinEnvs(&secrets)
 		if len(duplicates) > 0 {
@@ -210,17 +351,277 @@ func CreateSecret(c *fiber.Ctx) error {
 	// TODO(carlotta): add secret limitations per environment?
 	// This may be costly to perform if multiple environments are selected.
 
+	value, err := project.ParseSecretValue(data.Value)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.CreateSecretInvalidBody))
+	}
+
 	newSecret := models.Secret{
 		Key:          data.Key,
-		Value:        []byte(data.Value),
+		Value:        value,
+		ProjectID:    project.ID,
 		UserID:       userSessionID,
 		Environments: environments,
 	}
-	if err := db.Create(&newSecret).Error; err != nil {
+	return transaction(db, func(tx *gorm.DB) error {
+		if err := tx.Create(&newSecret).Error; errors.Is(err, gorm.ErrDuplicatedKey) {
+			return rollback(c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.CreateSecretKeyAlreadyExists)))
+		} else if err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := recordAuditEvent(c, tx, models.AuditEvent{
+			Action:      models.AuditSecretCreate,
+			ProjectID:   &project.ID,
+			ResourceIDs: models.AuditResources(append([]uuid.UUID{newSecret.ID}, environmentIDs...)...),
+		}); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := publishSecretEvent(
+			tx, events.SecretCreate, project.ID, models.GetEnvIDs(&environments), newSecret,
+		); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(newSecret)
+	})
+}
+
+type importPlan struct {
+	envVar    utils.EnvVar
+	empty     bool
+	existing  []models.Secret
+	remaining []models.Environment
+}
+
+func ImportSecrets(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	var data models.ReqImportSecrets
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.ImportSecretsInvalidBody))
+	}
+
+	if err := utils.Validate().Struct(data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.ImportSecretsInvalidBody))
+	}
+
+	projectID := utils.MustParseUUID(data.ProjectID)
+
+	var project models.Project
+	if err := db.Scopes(
+		models.MemberProjects(userSessionID),
+	).Where(
+		&models.Project{ID: projectID},
+	).First(&project).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.ImportSecretsNonExistentProject))
+	}
+
+	environmentIDs, err := utils.ParseUUIDs(data.EnvironmentIDs)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(newSecret)
+	var environments []models.Environment
+	if err := db.Find(
+		&environments, "id IN ? AND project_id=?", environmentIDs, project.ID,
+	).Error; err != nil || len(environments) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.ImportSecretsNonExistentEnv))
+	}
+
+	member, ok := models.Authorize(db, userSessionID, project.OrganizationID, utils.WriteSecrets)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	if !member.CanAccessEnvironments(db, models.GetEnvIDs(&environments), utils.AccessWrite) {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.EnvironmentPermissionDenied))
+	}
+
+	if project.EndToEnd {
+		return c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.EndToEndUnsupported))
+	}
+
+	envVars, err := utils.ParseSecretsFile(data.Format, data.Content)
+	if err != nil || len(envVars) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.ImportSecretsInvalidContent))
+	}
+
+	return transaction(db, func(tx *gorm.DB) error {
+		var plans []importPlan
+		for _, envVar := range envVars {
+			if err := utils.Validate().Var(envVar.Key, "required,secretkey,gte=2,lte=255"); err != nil {
+				return rollback(c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.ImportSecretsInvalidSecret)))
+			}
+
+			// empty values are skipped rather than failing the import
+			if len(envVar.Value) == 0 {
+				plans = append(plans, importPlan{envVar: envVar, empty: true})
+				continue
+			}
+
+			if err := utils.Validate().Var(envVar.Value, "lte=5000"); err != nil {
+				return rollback(c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.ImportSecretsInvalidSecret)))
+			}
+
+			// locked so that the plan can't go stale before it's applied
+			var secrets []models.Secret
+			if err := tx.Clauses(
+				clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: clause.CurrentTable}},
+			).Preload(
+				"Environments", "id in ? AND project_id=?", environmentIDs, project.ID,
+			).Find(
+				&secrets, "key=? AND project_id=?", envVar.Key, project.ID,
+			).Error; err != nil {
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+			}
+
+			plan := importPlan{envVar: envVar}
+			existingEnvIDs := make(map[uuid.UUID]bool)
+			for _, secret := range secrets {
+				if len(secret.Environments) > 0 {
+					plan.existing = append(plan.existing, secret)
+				}
+				for _, env := range secret.Environments {
+					existingEnvIDs[env.ID] = true
+				}
+			}
+
+			for _, env := range environments {
+				if !existingEnvIDs[env.ID] {
+					plan.remaining = append(plan.remaining, env)
+				}
+			}
+
+			if data.Conflict == "fail" && len(plan.existing) > 0 {
+				return rollback(c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.ImportSecretsKeyAlreadyExists)))
+			}
+
+			plans = append(plans, plan)
+		}
+
+		var report []models.ImportedSecret
+		var importedIDs []uuid.UUID
+		var createdSecrets, updatedSecrets []models.Secret
+		for _, plan := range plans {
+			if plan.empty {
+				report = append(report, models.ImportedSecret{
+					Key:          plan.envVar.Key,
+					Action:       "skipped",
+					Environments: models.GetEnvNames(&environments),
+				})
+				continue
+			}
+
+			if len(plan.existing) > 0 {
+				action := "skipped"
+				if data.Conflict == "overwrite" {
+					action = "updated"
+					for _, existingSecret := range plan.existing {
+						var secret models.Secret
+						if err := tx.Preload("Environments").First(&secret, "id=?", existingSecret.ID).Error; err != nil {
+							return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+						}
+
+						selectedEnvIDs := models.GetEnvIDs(&existingSecret.Environments)
+
+						// a secret shared with environments outside of the import gets a value within the selected ones instead
+						if len(secret.Environments) > len(selectedEnvIDs) {
+							for _, environmentID := range selectedEnvIDs {
+								if err := models.SetEnvironmentSecretValue(
+									tx, &project, secret.ID, environmentID, []byte(plan.envVar.Value),
+								); err != nil {
+									return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+								}
+							}
+						} else {
+							newValue, newNonce, sealVersion, err := project.SealSecretValue(
+								secret.ID, secret.Key, []byte(plan.envVar.Value),
+							)
+							if err != nil {
+								return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+							}
+
+							if err := tx.Model(&secret).Updates(
+								&models.Secret{Nonce: newNonce, Value: newValue, SealVersion: sealVersion},
+							).Error; err != nil {
+								return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+							}
+
+							if err := models.ClearEnvironmentSecretValues(tx, secret.ID, selectedEnvIDs); err != nil {
+								return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+							}
+						}
+
+						if err := models.CreateSecretRevision(tx, &secret); err != nil {
+							return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+						}
+
+						importedIDs = append(importedIDs, secret.ID)
+						updatedSecrets = append(updatedSecrets, secret)
+					}
+				}
+
+				report = append(report, models.ImportedSecret{
+					Key:          plan.envVar.Key,
+					Action:       action,
+					Environments: models.GetDupKeyinEnvs(&plan.existing),
+				})
+			}
+
+			if len(plan.remaining) > 0 {
+				newSecret := models.Secret{
+					Key:          plan.envVar.Key,
+					Value:        []byte(plan.envVar.Value),
+					ProjectID:    project.ID,
+					UserID:       userSessionID,
+					Environments: plan.remaining,
+				}
+				if err := tx.Create(&newSecret).Error; errors.Is(err, gorm.ErrDuplicatedKey) {
+					return rollback(c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.ImportSecretsKeyAlreadyExists)))
+				} else if err != nil {
+					return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+				}
+
+				importedIDs = append(importedIDs, newSecret.ID)
+				createdSecrets = append(createdSecrets, newSecret)
+
+				report = append(report, models.ImportedSecret{
+					Key:          plan.envVar.Key,
+					Action:       "created",
+					Environments: models.GetEnvNames(&plan.remaining),
+				})
+			}
+		}
+
+		if err := recordAuditEvent(c, tx, models.AuditEvent{
+			Action:      models.AuditSecretImport,
+			ProjectID:   &project.ID,
+			ResourceIDs: models.AuditResources(importedIDs...),
+		}); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if len(createdSecrets) > 0 {
+			if err := publishSecretEvent(
+				tx, events.SecretCreate, project.ID, models.GetEnvIDs(&environments), createdSecrets...,
+			); err != nil {
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+			}
+		}
+
+		if len(updatedSecrets) > 0 {
+			if err := publishSecretEvent(
+				tx, events.SecretUpdate, project.ID, models.GetEnvIDs(&environments), updatedSecrets...,
+			); err != nil {
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+			}
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(report)
+	})
 }
 
 func DeleteSecret(c *fiber.Ctx) error {
@@ -232,18 +633,56 @@ func DeleteSecret(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.DeleteSecretInvalidID))
 	}
 
-	var secret models.Secret
-	if err := db.Where(
-		&models.Environment{ID: utils.MustParseUUID(id), UserID: userSessionID},
-	).First(&secret).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.DeleteSecretNonExistentID))
-	}
+	return transaction(db, func(tx *gorm.DB) error {
+		var secret models.Secret
+		if err := tx.Scopes(
+			models.MemberProjectResources(userSessionID),
+		).Clauses(
+			clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: clause.CurrentTable}},
+		).Preload("Environments").Where(
+			&models.Secret{ID: utils.MustParseUUID(id)},
+		).First(&secret).Error; err != nil {
+			return rollback(c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.DeleteSecretNonExistentID)))
+		}
 
-	if err := db.Delete(&secret).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
-	}
+		member, ok := models.AuthorizeProject(tx, userSessionID, secret.ProjectID, utils.WriteSecrets)
+		if !ok {
+			return rollback(c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied)))
+		}
 
-	return c.Status(fiber.StatusCreated).SendString(fmt.Sprintf("Successfully removed the %s secret!", secret.Key))
+		if !member.CanAccessEnvironments(tx, models.GetEnvIDs(&secret.Environments), utils.AccessWrite) {
+			return rollback(c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.EnvironmentPermissionDenied)))
+		}
+
+		if !utils.IfMatch(c, secret.Version) {
+			return rollback(c.Status(fiber.StatusPreconditionFailed).JSON(utils.JSONError(utils.PreconditionFailed)))
+		}
+
+		// the revisions are kept so that the secret can be restored
+		if err := models.CreateSecretDeletionRevision(tx, &secret); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := tx.Delete(&secret).Error; err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := recordAuditEvent(c, tx, models.AuditEvent{
+			Action:      models.AuditSecretDelete,
+			ProjectID:   &secret.ProjectID,
+			ResourceIDs: models.AuditResources(append([]uuid.UUID{secret.ID}, models.GetEnvIDs(&secret.Environments)...)...),
+		}); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := publishSecretEvent(
+			tx, events.SecretDelete, secret.ProjectID, models.GetEnvIDs(&secret.Environments), secret,
+		); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		return c.Status(fiber.StatusCreated).SendString(fmt.Sprintf("Successfully removed the %s secret!", secret.Key))
+	})
 }
 
 func UpdateSecret(c *fiber.Ctx) error {
@@ -259,24 +698,46 @@ func UpdateSecret(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateSecretInvalidBody))
 	}
 
-	return db.Transaction(func(tx *gorm.DB) error {
+	return transaction(db, func(tx *gorm.DB) error {
 		parsedID := utils.MustParseUUID(data.ID)
 
 		var secret models.Secret
-		if err := tx.Where(&models.Secret{ID: parsedID, UserID: userSessionID}).First(&secret).Error; err != nil {
-			return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.UpdateSecretInvalidID))
+		if err := tx.Scopes(
+			models.MemberProjectResources(userSessionID),
+		).Clauses(
+			clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: clause.CurrentTable}},
+		).Preload("Environments").Where(
+			&models.Secret{ID: parsedID},
+		).First(&secret).Error; err != nil {
+			return rollback(c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.UpdateSecretInvalidID)))
 		}
 
 		environmentIDs, err := utils.ParseUUIDs(data.EnvironmentIDs)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
 		}
 
 		var environments []models.Environment
 		if err := tx.Find(
-			&environments, "id IN ? AND user_id=?", environmentIDs, userSessionID,
+			&environments, "id IN ? AND project_id=?", environmentIDs, secret.ProjectID,
 		).Error; err != nil || len(environments) == 0 {
-			return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.UpdateSecretNonExistentEnv))
+			return rollback(c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.UpdateSecretNonExistentEnv)))
+		}
+
+		member, ok := models.AuthorizeProject(tx, userSessionID, secret.ProjectID, utils.WriteSecrets)
+		if !ok {
+			return rollback(c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied)))
+		}
+
+		// both the current and the selected environments are written to
+		if !member.CanAccessEnvironments(
+			tx, append(models.GetEnvIDs(&secret.Environments), environmentIDs...), utils.AccessWrite,
+		) {
+			return rollback(c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.EnvironmentPermissionDenied)))
+		}
+
+		if !utils.IfMatch(c, secret.Version) {
+			return rollback(c.Status(fiber.StatusPreconditionFailed).JSON(utils.JSONError(utils.PreconditionFailed)))
 		}
 
 		var secrets []models.Secret
@@ -285,37 +746,456 @@ func UpdateSecret(c *fiber.Ctx) error {
 		).Not(
 			"id", parsedID,
 		).Find(
-			&secrets, "key=? AND user_id=?", data.Key, userSessionID,
+			&secrets, "key=? AND project_id=?", data.Key, secret.ProjectID,
 		).Error; err != nil || len(secrets) != 0 {
 			if err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
 			}
 
 			duplicates := models.GetDupKeyinEnvs(&secrets)
 			if len(duplicates) > 0 {
-				return c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.UpdateSecretKeyAlreadyExists))
+				return rollback(c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.UpdateSecretKeyAlreadyExists)))
 			}
 
 		}
 
-		newValue, newNonce, err := utils.CreateEncryptedSecretValue([]byte(data.Value))
+		project, err := models.GetSecretProject(tx, secret.ProjectID)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
 		}
 
-		if err = tx.Model(&secret).Association("Environments").Replace(environments); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+		value, err := project.ParseSecretValue(data.Value)
+		if err != nil {
+			return rollback(c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateSecretInvalidBody)))
+		}
+
+		newValue, newNonce, sealVersion, err := project.SealSecretValue(secret.ID, data.Key, value)
+		if err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		changedEnvIDs := append(models.GetEnvIDs(&secret.Environments), environmentIDs...)
+
+		if err = models.DetachSecretEnvironments(tx, secret.ID, environmentIDs); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
 		}
 
 		updatedSecret := models.Secret{
-			Key:   data.Key,
-			Nonce: newNonce,
-			Value: newValue,
+			Key:         data.Key,
+			Nonce:       newNonce,
+			Value:       newValue,
+			SealVersion: sealVersion,
+		}
+		if err = tx.Model(&secret).Updates(&updatedSecret).Error; errors.Is(err, gorm.ErrDuplicatedKey) {
+			return rollback(c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.UpdateSecretKeyAlreadyExists)))
+		} else if err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err = tx.Model(&secret).Association("Environments").Replace(environments); errors.Is(err, gorm.ErrDuplicatedKey) {
+			return rollback(c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.UpdateSecretKeyAlreadyExists)))
+		} else if err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err = models.CreateSecretRevision(tx, &secret); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := recordAuditEvent(c, tx, models.AuditEvent{
+			Action:      models.AuditSecretUpdate,
+			ProjectID:   &secret.ProjectID,
+			ResourceIDs: models.AuditResources(append([]uuid.UUID{secret.ID}, environmentIDs...)...),
+		}); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := publishSecretEvent(tx, events.SecretUpdate, secret.ProjectID, changedEnvIDs, secret); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		utils.SetETag(c, secret.Version)
+
+		return c.Status(fiber.StatusOK).JSON(secret)
+	})
+}
+
+// sets the secret's value within a single environment, attaching the secret to it when needed
+func UpdateSecretEnvironmentValue(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	var data models.ReqUpdateSecretEnvValue
+	if err := c.BodyParser(&data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateSecretEnvValueInvalidBody))
+	}
+
+	if err := utils.Validate().Struct(data); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateSecretEnvValueInvalidBody))
+	}
+
+	return transaction(db, func(tx *gorm.DB) error {
+		var secret models.Secret
+		if err := tx.Scopes(
+			models.MemberProjectResources(userSessionID),
+		).Clauses(
+			clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: clause.CurrentTable}},
+		).Preload("Environments").Where(
+			&models.Secret{ID: utils.MustParseUUID(data.ID)},
+		).First(&secret).Error; err != nil {
+			return rollback(c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.UpdateSecretEnvValueNonExistentID)))
+		}
+
+		var environment models.Environment
+		if err := tx.Where(
+			&models.Environment{ID: utils.MustParseUUID(data.EnvironmentID), ProjectID: secret.ProjectID},
+		).First(&environment).Error; err != nil {
+			return rollback(c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.UpdateSecretEnvValueNonExistentEnv)))
+		}
+
+		member, ok := models.AuthorizeProject(tx, userSessionID, secret.ProjectID, utils.WriteSecrets)
+		if !ok {
+			return rollback(c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied)))
+		}
+
+		if !member.CanAccessEnvironments(tx, []uuid.UUID{environment.ID}, utils.AccessWrite) {
+			return rollback(c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.EnvironmentPermissionDenied)))
+		}
+
+		if !utils.IfMatch(c, secret.Version) {
+			return rollback(c.Status(fiber.StatusPreconditionFailed).JSON(utils.JSONError(utils.PreconditionFailed)))
+		}
+
+		attached := false
+		for _, env := range secret.Environments {
+			if env.ID == environment.ID {
+				attached = true
+			}
+		}
+
+		if !attached {
+			if len(data.Value) == 0 {
+				return rollback(c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateSecretEnvValueInvalidBody)))
+			}
+
+			var secrets []models.Secret
+			if err := tx.Preload(
+				"Environments", "id=?", environment.ID,
+			).Not(
+				"id", secret.ID,
+			).Find(
+				&secrets, "key=? AND project_id=?", secret.Key, secret.ProjectID,
+			).Error; err != nil {
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+			}
+
+			if len(models.GetDupKeyinEnvs(&secrets)) > 0 {
+				return rollback(c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.UpdateSecretEnvValueKeyAlreadyExists)))
+			}
+
+			if err := tx.Model(&secret).Association("Environments").Append(&environment); errors.Is(err, gorm.ErrDuplicatedKey) {
+				return rollback(c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.UpdateSecretEnvValueKeyAlreadyExists)))
+			} else if err != nil {
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+			}
+		}
+
+		project, err := models.GetSecretProject(tx, secret.ProjectID)
+		if err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if len(data.Value) == 0 {
+			if err := models.ClearEnvironmentSecretValues(tx, secret.ID, []uuid.UUID{environment.ID}); err != nil {
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+			}
+		} else {
+			value, err := project.ParseSecretValue(data.Value)
+			if err != nil {
+				return rollback(c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.UpdateSecretEnvValueInvalidBody)))
+			}
+
+			if err := models.SetEnvironmentSecretValue(tx, &project, secret.ID, environment.ID, value); err != nil {
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+			}
+		}
+
+		if err := models.CreateSecretRevision(tx, &secret); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := recordAuditEvent(c, tx, models.AuditEvent{
+			Action:      models.AuditSecretUpdate,
+			ProjectID:   &secret.ProjectID,
+			ResourceIDs: models.AuditResources(secret.ID, environment.ID),
+		}); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := publishSecretEvent(
+			tx, events.SecretUpdate, secret.ProjectID, []uuid.UUID{environment.ID}, secret,
+		); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
 		}
-		if err = tx.Model(&secret).Updates(&updatedSecret).Error; err != nil {
+
+		utils.SetETag(c, secret.Version)
+
+		return c.Status(fiber.StatusOK).JSON(secret)
+	})
+}
+
+func GetSecretRevisionsBySecretID(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	id := c.Params("id")
+	if err := utils.Validate().Var(id, "required,uuid"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.GetSecretRevisionsInvalidID))
+	}
+
+	var revisions []models.SecretRevision
+	if err := db.Scopes(
+		models.MemberProjectResources(userSessionID),
+	).Where(
+		&models.SecretRevision{SecretID: utils.MustParseUUID(id)},
+	).Order("version DESC").Find(&revisions).Error; err != nil || len(revisions) == 0 {
+		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 		}
 
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.GetSecretRevisionsNonExistentID))
+	}
+
+	member, ok := models.AuthorizeProject(db, userSessionID, revisions[0].ProjectID, utils.ReadResources)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	environmentIDs, err := models.GetSecretRevisionEnvironmentIDs(db, revisions[0].SecretID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if !member.CanAccessAnyEnvironment(db, environmentIDs, utils.AccessRead) {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.EnvironmentPermissionDenied))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(revisions)
+}
+
+func GetSecretRevisionByID(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	id := c.Params("id")
+	if err := utils.Validate().Var(id, "required,uuid"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.GetSecretRevisionInvalidID))
+	}
+
+	var revision models.SecretRevision
+	if err := db.Scopes(
+		models.MemberProjectResources(userSessionID),
+	).Where(
+		&models.SecretRevision{ID: utils.MustParseUUID(id)},
+	).First(&revision).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.GetSecretRevisionNonExistentID))
+	}
+
+	member, ok := models.AuthorizeProject(db, userSessionID, revision.ProjectID, utils.ReadResources)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied))
+	}
+
+	environmentIDs, err := models.GetSecretRevisionEnvironmentIDs(db, revision.SecretID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if !member.CanAccessAnyEnvironment(db, environmentIDs, utils.AccessRead) {
+		return c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.EnvironmentPermissionDenied))
+	}
+
+	project, err := models.GetSecretProject(db, revision.ProjectID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	value, err := project.OpenSecretValue(revision.SecretID, revision.Key, revision.SealVersion, revision.Value, revision.Nonce)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	environmentValues, err := member.OpenEnvironmentSecretValues(db, &project, revision.SecretID, revision.EnvironmentValues)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action:      models.AuditSecretRevisionRead,
+		ProjectID:   &revision.ProjectID,
+		ResourceIDs: models.AuditResources(revision.SecretID, revision.ID),
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(
+		fiber.Map{
+			"createdAt":         revision.CreatedAt,
+			"environmentIDs":    revision.EnvironmentIDs,
+			"environmentValues": environmentValues,
+			"id":                revision.ID,
+			"key":               revision.Key,
+			"secretID":          revision.SecretID,
+			"value":             value,
+			"version":           revision.Version,
+			"deleted":           revision.Deleted,
+			"endToEnd":          project.EndToEnd,
+		},
+	)
+}
+
+func RestoreSecretRevision(c *fiber.Ctx) error {
+	db := database.GetConnection()
+	userSessionID := utils.GetSessionID(c)
+
+	id := c.Params("id")
+	if err := utils.Validate().Var(id, "required,uuid"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.JSONError(utils.RestoreSecretRevisionInvalidID))
+	}
+
+	return transaction(db, func(tx *gorm.DB) error {
+		var revision models.SecretRevision
+		if err := tx.Scopes(
+			models.MemberProjectResources(userSessionID),
+		).Where(
+			&models.SecretRevision{ID: utils.MustParseUUID(id)},
+		).First(&revision).Error; err != nil {
+			return rollback(c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.RestoreSecretRevisionNonExistentID)))
+		}
+
+		var secret models.Secret
+		err := tx.Clauses(
+			clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: clause.CurrentTable}},
+		).Preload("Environments").Where(
+			&models.Secret{ID: revision.SecretID},
+		).First(&secret).Error
+		deleted := errors.Is(err, gorm.ErrRecordNotFound)
+		if err != nil && !deleted {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		var environments []models.Environment
+		if err := tx.Find(
+			&environments, "id IN ? AND project_id=?", []uuid.UUID(revision.EnvironmentIDs), revision.ProjectID,
+		).Error; err != nil || len(environments) == 0 {
+			return rollback(c.Status(fiber.StatusNotFound).JSON(utils.JSONError(utils.RestoreSecretRevisionNonExistentEnv)))
+		}
+
+		member, ok := models.AuthorizeProject(tx, userSessionID, revision.ProjectID, utils.WriteSecrets)
+		if !ok {
+			return rollback(c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.PermissionDenied)))
+		}
+
+		if !member.CanAccessEnvironments(
+			tx, append(models.GetEnvIDs(&secret.Environments), models.GetEnvIDs(&environments)...), utils.AccessWrite,
+		) {
+			return rollback(c.Status(fiber.StatusForbidden).JSON(utils.JSONError(utils.EnvironmentPermissionDenied)))
+		}
+
+		// a secret that has been deleted doesn't match any ETag
+		if (deleted && len(c.Get(fiber.HeaderIfMatch)) > 0) || (!deleted && !utils.IfMatch(c, secret.Version)) {
+			return rollback(c.Status(fiber.StatusPreconditionFailed).JSON(utils.JSONError(utils.PreconditionFailed)))
+		}
+
+		environmentIDs := make([]uuid.UUID, 0, len(environments))
+		for _, env := range environments {
+			environmentIDs = append(environmentIDs, env.ID)
+		}
+
+		var secrets []models.Secret
+		if err := tx.Preload(
+			"Environments", "id in ?", environmentIDs,
+		).Not(
+			"id", revision.SecretID,
+		).Find(
+			&secrets, "key=? AND project_id=?", revision.Key, revision.ProjectID,
+		).Error; err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		duplicates := models.GetDupKeyinEnvs(&secrets)
+		if len(duplicates) > 0 {
+			return rollback(c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.RestoreSecretRevisionKeyAlreadyExists)))
+		}
+
+		changedEnvIDs := append(models.GetEnvIDs(&secret.Environments), environmentIDs...)
+
+		if deleted {
+			// the revision's values are bound to the secret's ID, so it's recreated with it and the sealing hooks are skipped
+			secret = models.Secret{
+				ID:          revision.SecretID,
+				Key:         revision.Key,
+				Value:       revision.Value,
+				Nonce:       revision.Nonce,
+				SealVersion: revision.SealVersion,
+				ProjectID:   revision.ProjectID,
+				UserID:      revision.UserID,
+			}
+			if err := tx.Session(&gorm.Session{SkipHooks: true}).Create(&secret).Error; err != nil {
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+			}
+		} else {
+			if err := models.DetachSecretEnvironments(tx, secret.ID, environmentIDs); err != nil {
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+			}
+
+			// the revision's value is bound to its key, so the two are restored together
+			restoredSecret := models.Secret{
+				Key:         revision.Key,
+				Nonce:       revision.Nonce,
+				Value:       revision.Value,
+				SealVersion: revision.SealVersion,
+			}
+			if err := tx.Model(&secret).Select("Key", "Nonce", "Value", "SealVersion").Updates(&restoredSecret).Error; errors.Is(err, gorm.ErrDuplicatedKey) {
+				return rollback(c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.RestoreSecretRevisionKeyAlreadyExists)))
+			} else if err != nil {
+				return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+			}
+		}
+
+		if err := tx.Model(&secret).Association("Environments").Replace(environments); errors.Is(err, gorm.ErrDuplicatedKey) {
+			return rollback(c.Status(fiber.StatusConflict).JSON(utils.JSONError(utils.RestoreSecretRevisionKeyAlreadyExists)))
+		} else if err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := models.RestoreEnvironmentSecretValues(tx, secret.ID, revision.EnvironmentValues); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := models.CreateSecretRevision(tx, &secret); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		if err := recordAuditEvent(c, tx, models.AuditEvent{
+			Action:      models.AuditSecretRestore,
+			ProjectID:   &secret.ProjectID,
+			ResourceIDs: models.AuditResources(secret.ID, revision.ID),
+		}); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		eventType := events.SecretUpdate
+		if deleted {
+			eventType = events.SecretCreate
+		}
+
+		if err := publishSecretEvent(tx, eventType, secret.ProjectID, changedEnvIDs, secret); err != nil {
+			return rollback(c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err)))
+		}
+
+		utils.SetETag(c, secret.Version)
+
 		return c.Status(fiber.StatusOK).JSON(secret)
 	})
 }
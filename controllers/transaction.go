@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// returned once the error response has been set, so that the transaction is rolled back
+var errRollback = errors.New("the transaction was rolled back")
+
+func rollback(err error) error {
+	if err != nil {
+		return err
+	}
+	return errRollback
+}
+
+func transaction(db *gorm.DB, fc func(tx *gorm.DB) error) error {
+	if err := db.Transaction(fc); !errors.Is(err, errRollback) {
+		return err
+	}
+	return nil
+}
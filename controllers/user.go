@@ -42,6 +42,13 @@ func Register(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
 
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		ActorUserID: newUser.ID,
+		Action:      models.AuditUserRegister,
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
 	if err = utils.SendAccountVerificationEmail(newUser.Name, newUser.Email, token); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
@@ -95,6 +102,13 @@ func Login(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
 
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		ActorUserID: existingUser.ID,
+		Action:      models.AuditUserLogin,
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
 	utils.SetSessionCookie(c, token, exp)
 	c.Status(fiber.StatusOK)
 	return nil
@@ -129,6 +143,13 @@ func VerifyAccount(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
 
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		ActorUserID: user.ID,
+		Action:      models.AuditUserVerify,
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
 	return c.Status(fiber.StatusCreated).SendString(fmt.Sprintf("Successfully verified %s!", user.Email))
 }
 
@@ -223,6 +244,13 @@ func UpdatePassword(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
 
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		ActorUserID: user.ID,
+		Action:      models.AuditUserUpdatePassword,
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
 	if err = utils.SendPasswordResetConfirmationEmail(user.Name, user.Email); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
@@ -249,6 +277,12 @@ func UpdateDisplayName(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
 	}
 
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action: models.AuditUserUpdateName,
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
 	token, exp, err := existingUser.GenerateSessionToken()
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
@@ -259,25 +293,6 @@ func UpdateDisplayName(c *fiber.Ctx) error {
 	return nil
 }
 
-func UpdateAPIKey(c *fiber.Ctx) error {
-	db := database.GetConnection()
-	userSessionID := utils.GetSessionID(c)
-
-	var user models.User
-	if err := db.Where(&models.User{ID: userSessionID}).First(&user).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(
-			errors.New("unable to locate the associated account from the current session")),
-		)
-	}
-
-	newAPIKey := utils.CreateBase64EncodedUUID()
-	if err := db.Model(&user).Update("APIKey", newAPIKey).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
-	}
-
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"apiKey": newAPIKey})
-}
-
 func GetAccountInfo(c *fiber.Ctx) error {
 	db := database.GetConnection()
 	userSessionID := utils.GetSessionID(c)
@@ -309,5 +324,11 @@ func DeleteAccount(c *fiber.Ctx) error {
 		)
 	}
 
+	if err := recordAuditEvent(c, db, models.AuditEvent{
+		Action: models.AuditUserDelete,
+	}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(utils.UnknownJSONError(err))
+	}
+
 	return Logout(c)
 }
@@ -29,7 +29,8 @@ func CreateConnection() *gorm.DB {
 	}
 
 	dbc, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logType),
+		Logger:         logger.Default.LogMode(logType),
+		TranslateError: true,
 	})
 	if err != nil {
 		log.Fatal("Failed to connect to the database")
@@ -0,0 +1,236 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+const channel = "nvi_events"
+
+// postgres rejects notifications of 8000 bytes or more
+const maxPayloadSize = 7900
+
+const subscriptionBuffer = 32
+
+const reconnectPause = 3 * time.Second
+
+const (
+	SecretCreate      = "secret.create"
+	SecretUpdate      = "secret.update"
+	SecretDelete      = "secret.delete"
+	EnvironmentDelete = "environment.delete"
+)
+
+type SecretVersion struct {
+	ID      uuid.UUID `json:"id"`
+	Key     string    `json:"key"`
+	Version int       `json:"version"`
+}
+
+type Event struct {
+	Type           string          `json:"type"`
+	ProjectID      uuid.UUID       `json:"projectID"`
+	EnvironmentIDs []uuid.UUID     `json:"environmentIDs"`
+	Secrets        []SecretVersion `json:"secrets,omitempty"`
+}
+
+// closed when it falls behind or the connection is lost, so the secrets should be read again before resubscribing
+type Subscription struct {
+	projectID      uuid.UUID
+	environmentIDs map[uuid.UUID]bool
+	events         chan Event
+	closed         bool
+}
+
+var (
+	mu            sync.Mutex
+	subscriptions = make(map[*Subscription]struct{})
+)
+
+func Subscribe(projectID uuid.UUID, environmentIDs []uuid.UUID) *Subscription {
+	subscription := &Subscription{
+		projectID:      projectID,
+		environmentIDs: make(map[uuid.UUID]bool, len(environmentIDs)),
+		events:         make(chan Event, subscriptionBuffer),
+	}
+	for _, id := range environmentIDs {
+		subscription.environmentIDs[id] = true
+	}
+
+	mu.Lock()
+	subscriptions[subscription] = struct{}{}
+	mu.Unlock()
+
+	return subscription
+}
+
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *Subscription) Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	s.close()
+}
+
+// must be called while holding the lock
+func (s *Subscription) close() {
+	if s.closed {
+		return
+	}
+
+	s.closed = true
+	delete(subscriptions, s)
+	close(s.events)
+}
+
+func (s *Subscription) matches(event *Event) bool {
+	if event.ProjectID != s.projectID {
+		return false
+	}
+
+	for _, id := range event.EnvironmentIDs {
+		if s.environmentIDs[id] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func dispatch(event Event) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for subscription := range subscriptions {
+		if !subscription.matches(&event) {
+			continue
+		}
+
+		select {
+		case subscription.events <- event:
+		default:
+			subscription.close()
+		}
+	}
+}
+
+func closeAll() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for subscription := range subscriptions {
+		subscription.close()
+	}
+}
+
+// notifications are only sent once the transaction commits
+func Publish(db *gorm.DB, event Event) error {
+	payloads, err := payloads(event)
+	if err != nil {
+		return err
+	}
+
+	for _, payload := range payloads {
+		if err := db.Exec("SELECT pg_notify(?, ?)", channel, string(payload)).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func payloads(event Event) ([][]byte, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload) <= maxPayloadSize || len(event.Secrets) < 2 {
+		return [][]byte{payload}, nil
+	}
+
+	half := len(event.Secrets) / 2
+	first, second := event, event
+	first.Secrets, second.Secrets = event.Secrets[:half], event.Secrets[half:]
+
+	firstPayloads, err := payloads(first)
+	if err != nil {
+		return nil, err
+	}
+
+	secondPayloads, err := payloads(second)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(firstPayloads, secondPayloads...), nil
+}
+
+func Listen(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		stdlibConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.New("the database connection doesn't support listening for notifications")
+		}
+
+		pgxConn := stdlibConn.Conn()
+		if _, err := pgxConn.Exec(ctx, "LISTEN "+channel); err != nil {
+			return err
+		}
+
+		// the connection goes back to the pool
+		defer func() {
+			pgxConn.Exec(context.Background(), "UNLISTEN "+channel)
+		}()
+
+		for {
+			notification, err := pgxConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+
+			var event Event
+			if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+				log.Printf("Unable to parse an event: %s", err.Error())
+				continue
+			}
+
+			dispatch(event)
+		}
+	})
+}
+
+// subscriptions are closed when the connection is lost since their events may have been missed
+func Start(db *gorm.DB) {
+	go func() {
+		for {
+			if err := Listen(context.Background(), db); err != nil {
+				log.Printf("Stopped listening for events: %s", err.Error())
+			}
+
+			closeAll()
+			time.Sleep(reconnectPause)
+		}
+	}()
+}
@@ -0,0 +1,117 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatchMatchingSubscriptions(t *testing.T) {
+	projectID := uuid.New()
+	environmentID := uuid.New()
+	otherEnvironmentID := uuid.New()
+
+	subscription := Subscribe(projectID, []uuid.UUID{environmentID})
+	otherEnvironment := Subscribe(projectID, []uuid.UUID{otherEnvironmentID})
+	otherProject := Subscribe(uuid.New(), []uuid.UUID{environmentID})
+
+	defer func() {
+		subscription.Close()
+		otherEnvironment.Close()
+		otherProject.Close()
+	}()
+
+	event := Event{
+		Type:           SecretCreate,
+		ProjectID:      projectID,
+		EnvironmentIDs: []uuid.UUID{environmentID},
+		Secrets:        []SecretVersion{{ID: uuid.New(), Key: "KEY", Version: 1}},
+	}
+
+	dispatch(event)
+
+	assert.Equal(t, 1, len(subscription.Events()))
+	assert.Equal(t, event, <-subscription.Events())
+	assert.Equal(t, 0, len(otherEnvironment.Events()))
+	assert.Equal(t, 0, len(otherProject.Events()))
+}
+
+func TestDispatchClosesSlowSubscription(t *testing.T) {
+	projectID := uuid.New()
+	environmentID := uuid.New()
+
+	subscription := Subscribe(projectID, []uuid.UUID{environmentID})
+	defer subscription.Close()
+
+	event := Event{Type: SecretUpdate, ProjectID: projectID, EnvironmentIDs: []uuid.UUID{environmentID}}
+	for i := 0; i <= subscriptionBuffer; i++ {
+		dispatch(event)
+	}
+
+	received := 0
+	for range subscription.Events() {
+		received++
+	}
+
+	mu.Lock()
+	_, subscribed := subscriptions[subscription]
+	mu.Unlock()
+
+	assert.Equal(t, subscriptionBuffer, received)
+	assert.False(t, subscribed)
+}
+
+func TestCloseAllSubscriptions(t *testing.T) {
+	subscription := Subscribe(uuid.New(), []uuid.UUID{uuid.New()})
+
+	closeAll()
+
+	_, ok := <-subscription.Events()
+
+	assert.False(t, ok)
+	assert.NotPanics(t, subscription.Close)
+}
+
+func TestPayloadsSplitLargeEvents(t *testing.T) {
+	event := Event{
+		Type:           SecretUpdate,
+		ProjectID:      uuid.New(),
+		EnvironmentIDs: []uuid.UUID{uuid.New()},
+	}
+	for i := 0; i < 500; i++ {
+		event.Secrets = append(event.Secrets, SecretVersion{ID: uuid.New(), Key: fmt.Sprintf("KEY_%d", i), Version: 1})
+	}
+
+	payloads, err := payloads(event)
+
+	var secrets []SecretVersion
+	for _, payload := range payloads {
+		var part Event
+		assert.Nil(t, json.Unmarshal(payload, &part))
+		assert.LessOrEqual(t, len(payload), maxPayloadSize)
+		assert.Equal(t, event.Type, part.Type)
+		assert.Equal(t, event.ProjectID, part.ProjectID)
+		assert.Equal(t, event.EnvironmentIDs, part.EnvironmentIDs)
+		secrets = append(secrets, part.Secrets...)
+	}
+
+	assert.Nil(t, err)
+	assert.Greater(t, len(payloads), 1)
+	assert.Equal(t, event.Secrets, secrets)
+}
+
+func TestPayloadsKeepSmallEvents(t *testing.T) {
+	event := Event{
+		Type:           EnvironmentDelete,
+		ProjectID:      uuid.New(),
+		EnvironmentIDs: []uuid.UUID{uuid.New()},
+	}
+
+	payloads, err := payloads(event)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(payloads))
+}
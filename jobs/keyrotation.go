@@ -0,0 +1,92 @@
+package jobs
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/mattcarlotta/nvi-api/models"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	keyRotationBatchSize  = 100
+	keyRotationBatchPause = 250 * time.Millisecond
+)
+
+// progress is saved after every batch, so an interrupted rotation continues where it left off
+func RotateMasterKey(db *gorm.DB) error {
+	keyID := utils.ActiveKeyProvider.KeyID()
+
+	var remaining int64
+	if err := db.Model(&models.Project{}).Scopes(
+		models.NotWrappedBy(keyID),
+	).Count(&remaining).Error; err != nil {
+		return err
+	}
+
+	var job models.KeyRotationJob
+	err := db.Where(&models.KeyRotationJob{TargetKeyID: keyID}).First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if remaining == 0 {
+			return nil
+		}
+
+		job = models.KeyRotationJob{TargetKeyID: keyID, Status: models.KeyRotationRunning, Total: remaining}
+		// another instance may have created the job first
+		if err := db.Where(
+			&models.KeyRotationJob{TargetKeyID: keyID},
+		).FirstOrCreate(&job).Error; err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if job.Status != models.KeyRotationRunning {
+		if job.Status == models.KeyRotationCompleted && remaining == 0 {
+			return nil
+		}
+
+		if err := db.Model(&job).Updates(map[string]interface{}{
+			"status": models.KeyRotationRunning, "error": "", "completed_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	for {
+		rewrapped, err := models.RewrapProjectDataKeys(db, keyRotationBatchSize)
+		if err != nil {
+			if updateErr := db.Model(&job).Updates(map[string]interface{}{
+				"status": models.KeyRotationFailed, "error": err.Error(),
+			}).Error; updateErr != nil {
+				return updateErr
+			}
+			return err
+		}
+
+		if rewrapped == 0 {
+			break
+		}
+
+		if err := db.Model(&job).Update("rewrapped", gorm.Expr("rewrapped + ?", rewrapped)).Error; err != nil {
+			return err
+		}
+
+		time.Sleep(keyRotationBatchPause)
+	}
+
+	return db.Model(&job).Updates(map[string]interface{}{
+		"status": models.KeyRotationCompleted, "completed_at": time.Now(),
+	}).Error
+}
+
+func StartMasterKeyRotation(db *gorm.DB) {
+	go func() {
+		if err := RotateMasterKey(db); err != nil {
+			log.Printf("Unable to rotate the master key: %s", err.Error())
+		}
+	}()
+}
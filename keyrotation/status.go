@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/mattcarlotta/nvi-api/database"
+	"github.com/mattcarlotta/nvi-api/models"
+	"github.com/mattcarlotta/nvi-api/utils"
+)
+
+func main() {
+	db := database.GetConnection()
+
+	status, err := models.GetKeyRotationStatus(db, utils.ActiveKeyProvider.KeyID())
+	if err != nil {
+		log.Fatalf("Unable to read the master key rotation status: %s", err.Error())
+	}
+
+	fmt.Printf("🔑 Active master key: %s\n", status.ActiveKeyID)
+	if status.Job != nil {
+		fmt.Printf("Rotation %s, %d of %d data keys re-wrapped\n", status.Job.Status, status.Job.Rewrapped, status.Job.Total)
+		if len(status.Job.Error) > 0 {
+			fmt.Printf("Error: %s\n", status.Job.Error)
+		}
+	}
+	fmt.Printf("%d data keys still need to be re-wrapped\n", status.Remaining)
+}
@@ -5,13 +5,29 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/mattcarlotta/nvi-api/database"
+	"github.com/mattcarlotta/nvi-api/events"
+	"github.com/mattcarlotta/nvi-api/jobs"
 	"github.com/mattcarlotta/nvi-api/middlewares"
+	"github.com/mattcarlotta/nvi-api/migrations"
 	"github.com/mattcarlotta/nvi-api/routes"
 	"github.com/mattcarlotta/nvi-api/utils"
 )
 
 func main() {
-	database.CreateConnection()
+	db := database.CreateConnection()
+
+	// the schema is only changed by the migrate command
+	pending, err := migrations.Pending(db)
+	if err != nil {
+		log.Fatalf("Unable to verify the database migrations: %s", err.Error())
+	}
+	if len(pending) > 0 {
+		log.Fatalf("The database has %d pending migrations, apply them with 'go run migrate/migrate.go up'", len(pending))
+	}
+
+	jobs.StartMasterKeyRotation(db)
+
+	events.Start(db)
 
 	app := fiber.New(fiber.Config{
 		ServerHeader: "nvi-api",
@@ -25,6 +41,10 @@ func main() {
 	routes.EnvironmentRoutes(app)
 	routes.SecretRoutes(app)
 	routes.ProjectRoutes(app)
+	routes.OrganizationRoutes(app)
+	routes.APIKeyRoutes(app)
+	routes.AuditRoutes(app)
+	routes.EncryptionRoutes(app)
 
 	log.Fatal(app.Listen(utils.GetEnv("PORT")))
 }
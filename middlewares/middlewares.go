@@ -1,6 +1,8 @@
 package middlewares
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -9,6 +11,8 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/encryptcookie"
 	"github.com/gofiber/fiber/v2/middleware/helmet"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/mattcarlotta/nvi-api/database"
+	"github.com/mattcarlotta/nvi-api/models"
 	"github.com/mattcarlotta/nvi-api/utils"
 )
 
@@ -36,17 +40,59 @@ func Setup(app *fiber.App) {
 	)
 }
 
-func RequiresAPIKey(c *fiber.Ctx) error {
-	apiKey := c.Query("apiKey")
-	if err := utils.Validate().Var(apiKey, "required,alphanum"); err != nil {
-		return c.Status(fiber.StatusUnauthorized).SendString(
-			"a valid apiKey must be supplied in order to use the cli endpoint",
-		)
-	}
+// the apiKey query ends up in access logs, so it's flagged as deprecated
+func RequiresAPIKey(access string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		apiKey, hasBearer := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !hasBearer {
+			apiKey = c.Query("apiKey")
+			if len(apiKey) > 0 {
+				c.Set("Deprecation", "true")
+				c.Set(fiber.HeaderWarning, `299 - "the apiKey query is deprecated, please use an Authorization: Bearer header instead"`)
+			}
+		}
 
-	c.Locals("apiKey", apiKey)
+		if err := utils.Validate().Var(apiKey, "required,alphanum"); err != nil {
+			return c.Status(fiber.StatusUnauthorized).SendString(
+				"a valid apiKey must be supplied in order to use the cli endpoint",
+			)
+		}
 
-	return c.Next()
+		db := database.GetConnection()
+
+		key, ok := models.FindAPIKey(db, apiKey)
+		if !ok {
+			return c.Status(fiber.StatusNotFound).SendString(
+				"the provided apiKey is not valid. please try again",
+			)
+		}
+
+		if key.IsRevoked() {
+			return c.Status(fiber.StatusUnauthorized).SendString(
+				"the provided apiKey has been revoked",
+			)
+		}
+
+		if key.IsExpired() {
+			return c.Status(fiber.StatusUnauthorized).SendString(
+				"the provided apiKey has expired",
+			)
+		}
+
+		if !utils.HasAccess(key.Access, access) {
+			return c.Status(fiber.StatusForbidden).SendString(
+				fmt.Sprintf("the provided apiKey doesn't have %s access", access),
+			)
+		}
+
+		if err := db.Model(&key).Update("last_used_at", time.Now()).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+		}
+
+		c.Locals("apiKey", key)
+
+		return c.Next()
+	}
 }
 
 func RequiresCookieSession(c *fiber.Ctx) error {
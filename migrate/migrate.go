@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/mattcarlotta/nvi-api/database"
+	"github.com/mattcarlotta/nvi-api/migrations"
+)
+
+const usage = `usage: go run migrate/migrate.go <command>
+
+commands:
+  up             applies every pending migration
+  down <steps>   rolls back the latest applied migrations (1 by default)
+  status         lists the migrations and whether they've been applied
+  create <name>  adds an empty up and down migration to the migrations directory`
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+
+	switch os.Args[1] {
+	case "up":
+		applied, err := migrations.Up(database.GetConnection())
+		for _, migration := range applied {
+			fmt.Printf("⬆️  Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Unable to apply migrations: %s", err.Error())
+		}
+
+		fmt.Printf("🎉 Migration complete, %d migrations were applied\n", len(applied))
+	case "down":
+		steps := 1
+		if len(os.Args) > 2 {
+			parsedSteps, err := strconv.Atoi(os.Args[2])
+			if err != nil || parsedSteps < 1 {
+				log.Fatalf("The number of steps must be a positive number: %s", os.Args[2])
+			}
+			steps = parsedSteps
+		}
+
+		rolledBack, err := migrations.Down(database.GetConnection(), steps)
+		for _, migration := range rolledBack {
+			fmt.Printf("⬇️  Rolled back %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Unable to roll back migrations: %s", err.Error())
+		}
+
+		fmt.Printf("🎉 Rollback complete, %d migrations were rolled back\n", len(rolledBack))
+	case "status":
+		statuses, err := migrations.Status(database.GetConnection())
+		if err != nil {
+			log.Fatalf("Unable to read the status of the migrations: %s", err.Error())
+		}
+
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Err != nil {
+				state += " (" + status.Err.Error() + ")"
+			}
+			fmt.Printf("%04d_%s: %s\n", status.Version, status.Name, state)
+		}
+	case "create":
+		if len(os.Args) < 3 {
+			log.Fatal(usage)
+		}
+
+		upPath, downPath, err := migrations.Create("migrations", os.Args[2])
+		if err != nil {
+			log.Fatalf("Unable to create the migration: %s", err.Error())
+		}
+
+		fmt.Printf("📝 Created %s and %s\n", upPath, downPath)
+	default:
+		log.Fatal(usage)
+	}
+}
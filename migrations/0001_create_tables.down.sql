@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS "project_key_shares";
+DROP TABLE IF EXISTS "key_rotation_jobs";
+DROP TABLE IF EXISTS "audit_events";
+DROP TABLE IF EXISTS "api_keys";
+DROP TABLE IF EXISTS "environment_restrictions";
+DROP TABLE IF EXISTS "secret_revisions";
+DROP TABLE IF EXISTS "environment_secrets";
+DROP TABLE IF EXISTS "secrets";
+DROP TABLE IF EXISTS "environments";
+DROP TABLE IF EXISTS "projects";
+DROP TABLE IF EXISTS "organization_invites";
+DROP TABLE IF EXISTS "organization_members";
+DROP TABLE IF EXISTS "organizations";
+DROP TABLE IF EXISTS "users";
@@ -0,0 +1,265 @@
+-- tables left by the old AutoMigrate script are altered in place and their rows backfilled at the end
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+ALTER TABLE IF EXISTS "users" ADD COLUMN IF NOT EXISTS "public_key" bytea;
+
+ALTER TABLE IF EXISTS "projects" ADD COLUMN IF NOT EXISTS "organization_id" uuid;
+ALTER TABLE IF EXISTS "projects" ADD COLUMN IF NOT EXISTS "end_to_end" boolean NOT NULL DEFAULT false;
+ALTER TABLE IF EXISTS "projects" ADD COLUMN IF NOT EXISTS "wrapped_data_key" bytea;
+ALTER TABLE IF EXISTS "projects" ADD COLUMN IF NOT EXISTS "master_key_id" varchar(64);
+ALTER TABLE IF EXISTS "projects" DROP CONSTRAINT IF EXISTS "fk_projects_user";
+DROP INDEX IF EXISTS "project_index";
+
+ALTER TABLE IF EXISTS "environments" ADD COLUMN IF NOT EXISTS "parent_id" uuid;
+ALTER TABLE IF EXISTS "environments" DROP CONSTRAINT IF EXISTS "fk_environments_user";
+DROP INDEX IF EXISTS "env_index";
+
+ALTER TABLE IF EXISTS "secrets" ADD COLUMN IF NOT EXISTS "project_id" uuid;
+ALTER TABLE IF EXISTS "secrets" ADD COLUMN IF NOT EXISTS "seal_version" bigint NOT NULL DEFAULT 0;
+ALTER TABLE IF EXISTS "secrets" DROP CONSTRAINT IF EXISTS "fk_secrets_user";
+
+CREATE TABLE IF NOT EXISTS "users" (
+	"id" uuid DEFAULT uuid_generate_v4(),
+	"name" varchar(64) NOT NULL,
+	"email" varchar(255) NOT NULL,
+	"password" bytea NOT NULL,
+	"verified" boolean DEFAULT false,
+	"public_key" bytea,
+	"created_at" timestamptz,
+	"updated_at" timestamptz,
+	PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+
+CREATE TABLE IF NOT EXISTS "organizations" (
+	"id" uuid DEFAULT uuid_generate_v4(),
+	"name" varchar(255) NOT NULL,
+	"owner_id" uuid,
+	"personal" boolean DEFAULT false,
+	"created_at" timestamptz,
+	"updated_at" timestamptz,
+	PRIMARY KEY ("id"),
+	CONSTRAINT "fk_organizations_owner" FOREIGN KEY ("owner_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "organization_index" ON "organizations" ("name", "owner_id");
+
+CREATE TABLE IF NOT EXISTS "organization_members" (
+	"id" uuid DEFAULT uuid_generate_v4(),
+	"organization_id" uuid,
+	"user_id" uuid,
+	"role" varchar(16) NOT NULL DEFAULT 'developer',
+	"created_at" timestamptz,
+	PRIMARY KEY ("id"),
+	CONSTRAINT "fk_organization_members_organization" FOREIGN KEY ("organization_id") REFERENCES "organizations"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+	CONSTRAINT "fk_organization_members_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "member_index" ON "organization_members" ("organization_id", "user_id");
+
+CREATE TABLE IF NOT EXISTS "organization_invites" (
+	"id" uuid DEFAULT uuid_generate_v4(),
+	"organization_id" uuid,
+	"email" varchar(255) NOT NULL,
+	"invited_by_id" uuid,
+	"role" varchar(16) NOT NULL DEFAULT 'developer',
+	"created_at" timestamptz,
+	PRIMARY KEY ("id"),
+	CONSTRAINT "fk_organization_invites_organization" FOREIGN KEY ("organization_id") REFERENCES "organizations"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "invite_index" ON "organization_invites" ("organization_id", "email");
+
+CREATE TABLE IF NOT EXISTS "projects" (
+	"id" uuid DEFAULT uuid_generate_v4(),
+	"name" varchar(255) NOT NULL,
+	"organization_id" uuid,
+	"user_id" uuid,
+	"end_to_end" boolean NOT NULL DEFAULT false,
+	"wrapped_data_key" bytea,
+	"master_key_id" varchar(64),
+	"created_at" timestamptz,
+	"updated_at" timestamptz,
+	PRIMARY KEY ("id"),
+	CONSTRAINT "fk_projects_organization" FOREIGN KEY ("organization_id") REFERENCES "organizations"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_projects_master_key_id" ON "projects" ("master_key_id");
+CREATE INDEX IF NOT EXISTS "project_index" ON "projects" ("name", "organization_id");
+
+CREATE TABLE IF NOT EXISTS "environments" (
+	"id" uuid DEFAULT uuid_generate_v4(),
+	"project_id" uuid,
+	"user_id" uuid,
+	"name" varchar(255) NOT NULL,
+	"created_at" timestamptz,
+	"updated_at" timestamptz,
+	"parent_id" uuid,
+	PRIMARY KEY ("id"),
+	CONSTRAINT "fk_environments_project" FOREIGN KEY ("project_id") REFERENCES "projects"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+	CONSTRAINT "fk_environments_parent" FOREIGN KEY ("parent_id") REFERENCES "environments"("id") ON DELETE SET NULL ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_environments_parent_id" ON "environments" ("parent_id");
+CREATE INDEX IF NOT EXISTS "env_index" ON "environments" ("project_id", "name");
+
+CREATE TABLE IF NOT EXISTS "secrets" (
+	"id" uuid DEFAULT uuid_generate_v4(),
+	"project_id" uuid,
+	"user_id" uuid,
+	"key" varchar(255) NOT NULL,
+	"value" bytea NOT NULL,
+	"nonce" bytea NOT NULL,
+	"seal_version" bigint NOT NULL DEFAULT 0,
+	"created_at" timestamptz,
+	"updated_at" timestamptz,
+	PRIMARY KEY ("id"),
+	CONSTRAINT "fk_secrets_project" FOREIGN KEY ("project_id") REFERENCES "projects"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_secrets_project_id" ON "secrets" ("project_id");
+
+CREATE TABLE IF NOT EXISTS "environment_secrets" (
+	"secret_id" uuid,
+	"environment_id" uuid,
+	PRIMARY KEY ("secret_id", "environment_id"),
+	CONSTRAINT "fk_environment_secrets_secret" FOREIGN KEY ("secret_id") REFERENCES "secrets"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+	CONSTRAINT "fk_environment_secrets_environment" FOREIGN KEY ("environment_id") REFERENCES "environments"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "secret_revisions" (
+	"id" uuid DEFAULT uuid_generate_v4(),
+	"secret_id" uuid,
+	"user_id" uuid,
+	"version" bigint NOT NULL,
+	"key" varchar(255) NOT NULL,
+	"value" bytea NOT NULL,
+	"nonce" bytea NOT NULL,
+	"seal_version" bigint NOT NULL DEFAULT 0,
+	"environment_ids" jsonb,
+	"created_at" timestamptz,
+	PRIMARY KEY ("id"),
+	CONSTRAINT "fk_secret_revisions_secret" FOREIGN KEY ("secret_id") REFERENCES "secrets"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "revision_index" ON "secret_revisions" ("secret_id");
+
+CREATE TABLE IF NOT EXISTS "environment_restrictions" (
+	"id" uuid DEFAULT uuid_generate_v4(),
+	"environment_id" uuid,
+	"role" varchar(16) NOT NULL,
+	"access" varchar(16) NOT NULL,
+	"created_at" timestamptz,
+	"updated_at" timestamptz,
+	PRIMARY KEY ("id"),
+	CONSTRAINT "fk_environment_restrictions_environment" FOREIGN KEY ("environment_id") REFERENCES "environments"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "restriction_index" ON "environment_restrictions" ("environment_id", "role");
+
+CREATE TABLE IF NOT EXISTS "api_keys" (
+	"id" uuid DEFAULT uuid_generate_v4(),
+	"user_id" uuid,
+	"name" varchar(255) NOT NULL,
+	"prefix" varchar(16) NOT NULL,
+	"hash" bytea NOT NULL,
+	"project_ids" jsonb,
+	"environment_ids" jsonb,
+	"access" varchar(16) NOT NULL DEFAULT 'read',
+	"expires_at" timestamptz,
+	"last_used_at" timestamptz,
+	"revoked_at" timestamptz,
+	"created_at" timestamptz,
+	PRIMARY KEY ("id"),
+	CONSTRAINT "fk_api_keys_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_api_keys_prefix" ON "api_keys" ("prefix");
+CREATE INDEX IF NOT EXISTS "apikey_index" ON "api_keys" ("user_id", "name");
+
+CREATE TABLE IF NOT EXISTS "audit_events" (
+	"id" uuid DEFAULT uuid_generate_v4(),
+	"sequence" bigint NOT NULL,
+	"actor_user_id" uuid,
+	"api_key_id" uuid,
+	"action" varchar(64) NOT NULL,
+	"organization_id" uuid,
+	"project_id" uuid,
+	"resource_ids" jsonb,
+	"ip" varchar(64),
+	"user_agent" text,
+	"created_at" timestamptz,
+	"prev_hash" bytea,
+	"hash" bytea NOT NULL,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "audit_project_index" ON "audit_events" ("project_id", "created_at");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_organization_id" ON "audit_events" ("organization_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_action" ON "audit_events" ("action");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_actor_user_id" ON "audit_events" ("actor_user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_audit_events_sequence" ON "audit_events" ("sequence");
+
+CREATE TABLE IF NOT EXISTS "key_rotation_jobs" (
+	"id" uuid DEFAULT uuid_generate_v4(),
+	"target_key_id" varchar(64) NOT NULL,
+	"status" varchar(16) NOT NULL,
+	"total" bigint NOT NULL DEFAULT 0,
+	"rewrapped" bigint NOT NULL DEFAULT 0,
+	"error" text,
+	"created_at" timestamptz,
+	"updated_at" timestamptz,
+	"completed_at" timestamptz,
+	PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_key_rotation_jobs_target_key_id" ON "key_rotation_jobs" ("target_key_id");
+
+CREATE TABLE IF NOT EXISTS "project_key_shares" (
+	"id" uuid DEFAULT uuid_generate_v4(),
+	"project_id" uuid,
+	"user_id" uuid,
+	"shared_by_user_id" uuid,
+	"encrypted_key" bytea NOT NULL,
+	"created_at" timestamptz,
+	"updated_at" timestamptz,
+	PRIMARY KEY ("id"),
+	CONSTRAINT "fk_project_key_shares_project" FOREIGN KEY ("project_id") REFERENCES "projects"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+	CONSTRAINT "fk_project_key_shares_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "keyshare_index" ON "project_key_shares" ("project_id", "user_id");
+
+-- every user gets a personal organization that holds their projects
+INSERT INTO "organizations" ("name", "owner_id", "personal", "created_at", "updated_at")
+SELECT 'personal', u."id", true, now(), now()
+FROM "users" u
+WHERE NOT EXISTS (SELECT 1 FROM "organizations" o WHERE o."owner_id" = u."id" AND o."personal");
+
+INSERT INTO "organization_members" ("organization_id", "user_id", "role", "created_at")
+SELECT o."id", o."owner_id", 'owner', now()
+FROM "organizations" o
+WHERE o."personal" AND NOT EXISTS (
+	SELECT 1 FROM "organization_members" m WHERE m."organization_id" = o."id" AND m."user_id" = o."owner_id"
+);
+
+UPDATE "projects" p SET "organization_id" = o."id"
+FROM "organizations" o
+WHERE p."organization_id" IS NULL AND o."owner_id" = p."user_id" AND o."personal";
+
+-- secrets that aren't attached to any environment have no project and are dropped
+UPDATE "secrets" s SET "project_id" = (
+	SELECT e."project_id"
+	FROM "environment_secrets" es
+	JOIN "environments" e ON e."id" = es."environment_id"
+	WHERE es."secret_id" = s."id"
+	LIMIT 1
+)
+WHERE s."project_id" IS NULL;
+DELETE FROM "secrets" WHERE "project_id" IS NULL;
+
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_projects_organization') THEN
+		ALTER TABLE "projects" ADD CONSTRAINT "fk_projects_organization"
+			FOREIGN KEY ("organization_id") REFERENCES "organizations"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+	END IF;
+
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_environments_parent') THEN
+		ALTER TABLE "environments" ADD CONSTRAINT "fk_environments_parent"
+			FOREIGN KEY ("parent_id") REFERENCES "environments"("id") ON DELETE SET NULL ON UPDATE CASCADE;
+	END IF;
+
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_secrets_project') THEN
+		ALTER TABLE "secrets" ADD CONSTRAINT "fk_secrets_project"
+			FOREIGN KEY ("project_id") REFERENCES "projects"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+	END IF;
+END $$;
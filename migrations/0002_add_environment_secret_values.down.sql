@@ -0,0 +1,4 @@
+ALTER TABLE "secret_revisions" DROP COLUMN IF EXISTS "environment_values";
+ALTER TABLE "environment_secrets" DROP COLUMN IF EXISTS "seal_version";
+ALTER TABLE "environment_secrets" DROP COLUMN IF EXISTS "nonce";
+ALTER TABLE "environment_secrets" DROP COLUMN IF EXISTS "value";
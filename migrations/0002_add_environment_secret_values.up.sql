@@ -0,0 +1,5 @@
+-- secrets that already share a key are merged by the merge_secrets_by_key data migration
+ALTER TABLE "environment_secrets" ADD COLUMN IF NOT EXISTS "value" bytea;
+ALTER TABLE "environment_secrets" ADD COLUMN IF NOT EXISTS "nonce" bytea;
+ALTER TABLE "environment_secrets" ADD COLUMN IF NOT EXISTS "seal_version" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "secret_revisions" ADD COLUMN IF NOT EXISTS "environment_values" jsonb;
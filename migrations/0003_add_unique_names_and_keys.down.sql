@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS "environment_secret_key" ON "environment_secrets";
+DROP FUNCTION IF EXISTS "set_environment_secret_key"();
+
+DROP INDEX IF EXISTS "environment_secret_key_index";
+ALTER TABLE "environment_secrets" DROP CONSTRAINT IF EXISTS "fk_environment_secrets_secret_key";
+DROP INDEX IF EXISTS "secret_key_index";
+ALTER TABLE "environment_secrets" DROP COLUMN IF EXISTS "key";
+
+DROP INDEX IF EXISTS "env_index";
+CREATE INDEX "env_index" ON "environments" ("project_id", "name");
+
+DROP INDEX IF EXISTS "project_index";
+CREATE INDEX "project_index" ON "projects" ("name", "organization_id");
@@ -0,0 +1,60 @@
+-- duplicates created by concurrent requests are renamed before the constraints are added
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM "projects" GROUP BY "organization_id", "name" HAVING COUNT(*) > 1) THEN
+		RAISE EXCEPTION 'an organization has more than one project with the same name, rename them before migrating';
+	END IF;
+
+	IF EXISTS (SELECT 1 FROM "environments" GROUP BY "project_id", "name" HAVING COUNT(*) > 1) THEN
+		RAISE EXCEPTION 'a project has more than one environment with the same name, rename them before migrating';
+	END IF;
+
+	IF EXISTS (
+		SELECT 1
+		FROM "environment_secrets" es
+		JOIN "secrets" s ON s."id" = es."secret_id"
+		GROUP BY es."environment_id", s."key"
+		HAVING COUNT(*) > 1
+	) THEN
+		RAISE EXCEPTION 'an environment has more than one secret with the same key, rename them before migrating';
+	END IF;
+END $$;
+
+DROP INDEX IF EXISTS "project_index";
+CREATE UNIQUE INDEX "project_index" ON "projects" ("name", "organization_id");
+
+DROP INDEX IF EXISTS "env_index";
+CREATE UNIQUE INDEX "env_index" ON "environments" ("project_id", "name");
+
+-- the key is copied onto environment_secrets so it can be unique per environment
+ALTER TABLE "environment_secrets" ADD COLUMN "key" varchar(255);
+UPDATE "environment_secrets" es SET "key" = s."key" FROM "secrets" s WHERE s."id" = es."secret_id";
+ALTER TABLE "environment_secrets" ALTER COLUMN "key" SET NOT NULL;
+
+CREATE UNIQUE INDEX "secret_key_index" ON "secrets" ("id", "key");
+ALTER TABLE "environment_secrets" ADD CONSTRAINT "fk_environment_secrets_secret_key"
+	FOREIGN KEY ("secret_id", "key") REFERENCES "secrets"("id", "key") ON DELETE CASCADE ON UPDATE CASCADE;
+CREATE UNIQUE INDEX "environment_secret_key_index" ON "environment_secrets" ("environment_id", "key");
+
+-- "ON CONFLICT DO NOTHING" would silently skip a taken key, so it's reported as a violation here
+CREATE OR REPLACE FUNCTION "set_environment_secret_key"() RETURNS trigger AS $$
+BEGIN
+	SELECT "key" INTO NEW."key" FROM "secrets" WHERE "id" = NEW."secret_id";
+
+	PERFORM pg_advisory_xact_lock(hashtext(NEW."environment_id"::text || NEW."key"));
+	IF EXISTS (
+		SELECT 1
+		FROM "environment_secrets"
+		WHERE "environment_id" = NEW."environment_id" AND "key" = NEW."key" AND "secret_id" <> NEW."secret_id"
+	) THEN
+		RAISE unique_violation USING
+			MESSAGE = format('the key "%s" already exists within the environment', NEW."key"),
+			CONSTRAINT = 'environment_secret_key_index';
+	END IF;
+
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "environment_secret_key" BEFORE INSERT ON "environment_secrets"
+	FOR EACH ROW EXECUTE PROCEDURE "set_environment_secret_key"();
@@ -0,0 +1,3 @@
+ALTER TABLE "secrets" DROP COLUMN IF EXISTS "version";
+ALTER TABLE "environments" DROP COLUMN IF EXISTS "version";
+ALTER TABLE "projects" DROP COLUMN IF EXISTS "version";
@@ -0,0 +1,8 @@
+-- a secret is at the version of its latest revision
+ALTER TABLE "projects" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+ALTER TABLE "environments" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+ALTER TABLE "secrets" ADD COLUMN "version" bigint NOT NULL DEFAULT 1;
+
+UPDATE "secrets" s SET "version" = r."version"
+FROM (SELECT "secret_id", MAX("version") AS "version" FROM "secret_revisions" GROUP BY "secret_id") r
+WHERE r."secret_id" = s."id";
@@ -0,0 +1,7 @@
+-- the revisions of deleted secrets can't reference their secret again
+DELETE FROM "secret_revisions" r WHERE NOT EXISTS (SELECT 1 FROM "secrets" s WHERE s."id" = r."secret_id");
+ALTER TABLE "secret_revisions" DROP COLUMN IF EXISTS "deleted";
+ALTER TABLE "secret_revisions" ADD CONSTRAINT "fk_secret_revisions_secret" FOREIGN KEY ("secret_id") REFERENCES "secrets"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+DROP INDEX IF EXISTS "revision_project_index";
+ALTER TABLE "secret_revisions" DROP CONSTRAINT IF EXISTS "fk_secret_revisions_project";
+ALTER TABLE "secret_revisions" DROP COLUMN IF EXISTS "project_id";
@@ -0,0 +1,9 @@
+-- revisions outlive their secret; the secret ID is kept since their values are bound to it
+ALTER TABLE "secret_revisions" ADD COLUMN "project_id" uuid;
+UPDATE "secret_revisions" r SET "project_id" = s."project_id" FROM "secrets" s WHERE s."id" = r."secret_id";
+ALTER TABLE "secret_revisions" ALTER COLUMN "project_id" SET NOT NULL;
+ALTER TABLE "secret_revisions" ADD CONSTRAINT "fk_secret_revisions_project" FOREIGN KEY ("project_id") REFERENCES "projects"("id") ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE "secret_revisions" DROP CONSTRAINT IF EXISTS "fk_secret_revisions_secret";
+CREATE INDEX IF NOT EXISTS "revision_project_index" ON "secret_revisions" ("project_id");
+
+ALTER TABLE "secret_revisions" ADD COLUMN "deleted" boolean NOT NULL DEFAULT false;
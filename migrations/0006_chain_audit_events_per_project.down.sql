@@ -0,0 +1,10 @@
+-- the events of separate chains can't be put back into a single chain without breaking it
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM "audit_events" WHERE "chain_id" <> '00000000-0000-0000-0000-000000000000') THEN
+		RAISE EXCEPTION 'audit events have been chained per project and can''t be merged into a single chain';
+	END IF;
+END $$;
+DROP INDEX IF EXISTS "audit_chain_sequence_index";
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_audit_events_sequence" ON "audit_events" ("sequence");
+ALTER TABLE "audit_events" DROP COLUMN IF EXISTS "chain_id";
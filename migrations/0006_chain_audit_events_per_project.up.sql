@@ -0,0 +1,5 @@
+-- existing events stay in the shared nil chain
+ALTER TABLE "audit_events" ADD COLUMN "chain_id" uuid NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000';
+ALTER TABLE "audit_events" ALTER COLUMN "chain_id" DROP DEFAULT;
+DROP INDEX IF EXISTS "idx_audit_events_sequence";
+CREATE UNIQUE INDEX IF NOT EXISTS "audit_chain_sequence_index" ON "audit_events" ("chain_id", "sequence");
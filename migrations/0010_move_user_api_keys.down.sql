@@ -0,0 +1,2 @@
+-- a user's key can't be recovered from its hash, so the named keys are kept as they are
+SELECT 1;
@@ -0,0 +1,15 @@
+-- the old per-user key keeps working as a named key with write access
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT 1 FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'api_key'
+	) THEN
+		INSERT INTO "api_keys" ("user_id", "name", "prefix", "hash", "access", "created_at")
+		SELECT "id", 'legacy', left("api_key", 8), sha256(convert_to("api_key", 'UTF8')), 'write', now()
+		FROM "users"
+		WHERE "api_key" <> '';
+
+		ALTER TABLE "users" DROP COLUMN "api_key";
+	END IF;
+END $$;
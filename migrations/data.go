@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"github.com/mattcarlotta/nvi-api/models"
+	"gorm.io/gorm"
+)
+
+// rolling a data migration back only removes its record
+var dataMigrations = []Migration{
+	{Version: 7, Name: "create_project_data_keys", apply: createProjectDataKeys},
+	{Version: 8, Name: "reseal_secret_values", apply: resealSecretValues},
+	{Version: 9, Name: "merge_secrets_by_key", apply: mergeSecretsByKey},
+}
+
+func createProjectDataKeys(tx *gorm.DB) error {
+	for {
+		created, err := models.CreateLegacyProjectDataKeys(tx, 50)
+		if err != nil || created == 0 {
+			return err
+		}
+	}
+}
+
+func resealSecretValues(tx *gorm.DB) error {
+	for {
+		resealed, err := models.ResealSecretValues(tx, 500)
+		if err != nil || resealed == 0 {
+			return err
+		}
+	}
+}
+
+func mergeSecretsByKey(tx *gorm.DB) error {
+	for {
+		merged, err := models.MergeSecretsByKey(tx, 100)
+		if err != nil || merged == 0 {
+			return err
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+var (
+	ErrChecksumMismatch = errors.New("the migration has changed since it was applied")
+	ErrUnknownMigration = errors.New("the applied migration doesn't exist")
+	ErrInvalidName      = errors.New("a migration name may only contain lowercase letters, numbers and underscores")
+)
+
+var fileRegex = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+var nameRegex = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+const lockID = 8151992
+
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+	apply    func(tx *gorm.DB) error
+}
+
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"type:varchar(255);not null"`
+	Checksum  string    `gorm:"type:varchar(64);not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+	Err       error
+}
+
+type MigrationError struct {
+	Err     error
+	Version int64
+	Name    string
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("migration %04d (%s): %v", e.Version, e.Name, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// covers both directions, so editing either one after it has been applied is caught
+func checksum(up string, down string) string {
+	sum := sha256.Sum256([]byte(up + "\x00" + down))
+	return hex.EncodeToString(sum[:])
+}
+
+func Load() ([]Migration, error) {
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := fileRegex.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unable to parse the migration file name '%s'", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		contents, err := files.ReadFile(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %04d has more than one name: '%s' and '%s'", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if len(migration.Up) == 0 || len(migration.Down) == 0 {
+			return nil, fmt.Errorf("migration %04d (%s) must have both an up and a down file", migration.Version, migration.Name)
+		}
+
+		migration.Checksum = checksum(migration.Up, migration.Down)
+		migrations = append(migrations, *migration)
+	}
+
+	for _, migration := range dataMigrations {
+		if _, ok := byVersion[migration.Version]; ok {
+			return nil, fmt.Errorf("migration %04d (%s) has the same version as a migration file", migration.Version, migration.Name)
+		}
+
+		// data migrations are matched by name since their code changes with the models
+		migration.Checksum = checksum("data:"+migration.Name, "")
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func ensureSchemaTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS "schema_migrations" (
+		"version" bigint PRIMARY KEY,
+		"name" varchar(255) NOT NULL,
+		"checksum" varchar(64) NOT NULL,
+		"applied_at" timestamptz NOT NULL
+	)`).Error
+}
+
+func getApplied(db *gorm.DB) ([]SchemaMigration, error) {
+	if err := ensureSchemaTable(db); err != nil {
+		return nil, err
+	}
+
+	var applied []SchemaMigration
+	err := db.Order("version").Find(&applied).Error
+	return applied, err
+}
+
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := getApplied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	appliedByVersion := make(map[int64]SchemaMigration, len(applied))
+	for _, migration := range applied {
+		appliedByVersion[migration.Version] = migration
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Migration: migration}
+		if schemaMigration, ok := appliedByVersion[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &schemaMigration.AppliedAt
+			if schemaMigration.Checksum != migration.Checksum {
+				status.Err = &MigrationError{Err: ErrChecksumMismatch, Version: migration.Version, Name: migration.Name}
+			}
+			delete(appliedByVersion, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, schemaMigration := range applied {
+		if _, ok := appliedByVersion[schemaMigration.Version]; ok {
+			appliedAt := schemaMigration.AppliedAt
+			statuses = append(statuses, MigrationStatus{
+				Migration: Migration{Version: schemaMigration.Version, Name: schemaMigration.Name},
+				Applied:   true,
+				AppliedAt: &appliedAt,
+				Err:       &MigrationError{Err: ErrUnknownMigration, Version: schemaMigration.Version, Name: schemaMigration.Name},
+			})
+		}
+	}
+
+	return statuses, nil
+}
+
+// changed or missing applied migrations are an error since the schema can't be trusted
+func Pending(db *gorm.DB) ([]Migration, error) {
+	statuses, err := Status(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, status := range statuses {
+		if status.Err != nil {
+			return nil, status.Err
+		}
+
+		if !status.Applied {
+			pending = append(pending, status.Migration)
+		}
+	}
+
+	return pending, nil
+}
+
+func Up(db *gorm.DB) ([]Migration, error) {
+	pending, err := Pending(db)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]Migration, 0, len(pending))
+	for _, migration := range pending {
+		alreadyApplied := false
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockID).Error; err != nil {
+				return err
+			}
+
+			// another instance may have applied it while the lock was being waited on
+			var count int64
+			if err := tx.Model(&SchemaMigration{}).Where("version=?", migration.Version).Count(&count).Error; err != nil {
+				return err
+			}
+
+			if count > 0 {
+				alreadyApplied = true
+				return nil
+			}
+
+			if migration.apply != nil {
+				if err := migration.apply(tx); err != nil {
+					return err
+				}
+			} else if err := tx.Exec(migration.Up).Error; err != nil {
+				return err
+			}
+
+			return tx.Create(&SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				Checksum:  migration.Checksum,
+				AppliedAt: time.Now(),
+			}).Error
+		}); err != nil {
+			return applied, &MigrationError{Err: err, Version: migration.Version, Name: migration.Name}
+		}
+
+		if !alreadyApplied {
+			applied = append(applied, migration)
+		}
+	}
+
+	return applied, nil
+}
+
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]Migration, len(migrations))
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	applied, err := getApplied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	rolledBack := make([]Migration, 0, steps)
+	for i := len(applied) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+		migration, ok := byVersion[applied[i].Version]
+		if !ok {
+			return rolledBack, &MigrationError{Err: ErrUnknownMigration, Version: applied[i].Version, Name: applied[i].Name}
+		}
+
+		if migration.Checksum != applied[i].Checksum {
+			return rolledBack, &MigrationError{Err: ErrChecksumMismatch, Version: migration.Version, Name: migration.Name}
+		}
+
+		alreadyRolledBack := false
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockID).Error; err != nil {
+				return err
+			}
+
+			result := tx.Where("version=?", migration.Version).Delete(&SchemaMigration{})
+			if result.Error != nil {
+				return result.Error
+			}
+
+			// another instance may have rolled it back while the lock was being waited on
+			if result.RowsAffected == 0 {
+				alreadyRolledBack = true
+				return nil
+			}
+
+			if migration.apply != nil {
+				return nil
+			}
+
+			return tx.Exec(migration.Down).Error
+		}); err != nil {
+			return rolledBack, &MigrationError{Err: err, Version: migration.Version, Name: migration.Name}
+		}
+
+		if !alreadyRolledBack {
+			rolledBack = append(rolledBack, migration)
+		}
+	}
+
+	return rolledBack, nil
+}
+
+func Create(dir string, name string) (string, string, error) {
+	if !nameRegex.MatchString(name) {
+		return "", "", ErrInvalidName
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	var latestVersion int64
+	for _, migration := range dataMigrations {
+		if migration.Version > latestVersion {
+			latestVersion = migration.Version
+		}
+	}
+	for _, entry := range entries {
+		if match := fileRegex.FindStringSubmatch(entry.Name()); match != nil {
+			version, err := strconv.ParseInt(match[1], 10, 64)
+			if err != nil {
+				return "", "", err
+			}
+			if version > latestVersion {
+				latestVersion = version
+			}
+		}
+	}
+
+	prefix := filepath.Join(dir, fmt.Sprintf("%04d_%s", latestVersion+1, name))
+	upPath, downPath := prefix+".up.sql", prefix+".down.sql"
+
+	if err := os.WriteFile(upPath, []byte("-- "+name+"\n"), 0o644); err != nil {
+		return "", "", err
+	}
+
+	if err := os.WriteFile(downPath, []byte("-- reverts "+name+"\n"), 0o644); err != nil {
+		return "", "", err
+	}
+
+	return upPath, downPath, nil
+}
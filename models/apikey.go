@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// an empty list of project or environment IDs grants access to all of them
+type APIKey struct {
+	ID             uuid.UUID                      `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	UserID         uuid.UUID                      `gorm:"type:uuid;index:apikey_index" json:"userID"`
+	User           User                           `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Name           string                         `gorm:"type:varchar(255);index:apikey_index;not null" json:"name"`
+	Prefix         string                         `gorm:"type:varchar(16);index;not null" json:"prefix"`
+	Hash           []byte                         `gorm:"not null" json:"-"`
+	Key            string                         `gorm:"-" json:"key,omitempty"`
+	ProjectIDs     datatypes.JSONSlice[uuid.UUID] `json:"projectIDs"`
+	EnvironmentIDs datatypes.JSONSlice[uuid.UUID] `json:"environmentIDs"`
+	Access         string                         `gorm:"type:varchar(16);not null;default:'read'" json:"access"`
+	ExpiresAt      *time.Time                     `json:"expiresAt"`
+	LastUsedAt     *time.Time                     `json:"lastUsedAt"`
+	RevokedAt      *time.Time                     `json:"revokedAt"`
+	CreatedAt      time.Time                      `json:"createdAt"`
+}
+
+// only the prefix and hash are stored
+func (key *APIKey) BeforeCreate(tx *gorm.DB) (err error) {
+	key.Key = utils.CreateBase64EncodedUUID()
+	tx.Statement.SetColumn("Prefix", utils.GetAPIKeyPrefix(key.Key))
+	tx.Statement.SetColumn("Hash", utils.HashAPIKey(key.Key))
+
+	return nil
+}
+
+func FindAPIKey(db *gorm.DB, apiKey string) (APIKey, bool) {
+	var candidates []APIKey
+	if err := db.Where(&APIKey{Prefix: utils.GetAPIKeyPrefix(apiKey)}).Find(&candidates).Error; err != nil {
+		return APIKey{}, false
+	}
+
+	for _, candidate := range candidates {
+		if utils.CompareAPIKeyHash(candidate.Hash, apiKey) {
+			return candidate, true
+		}
+	}
+
+	return APIKey{}, false
+}
+
+func (key *APIKey) IsRevoked() bool {
+	return key.RevokedAt != nil
+}
+
+func (key *APIKey) IsExpired() bool {
+	return key.ExpiresAt != nil && key.ExpiresAt.Before(time.Now())
+}
+
+func (key *APIKey) ProjectScope(db *gorm.DB) *gorm.DB {
+	if len(key.ProjectIDs) == 0 {
+		return db
+	}
+	return db.Where("id IN ?", []uuid.UUID(key.ProjectIDs))
+}
+
+func (key *APIKey) EnvironmentScope(db *gorm.DB) *gorm.DB {
+	if len(key.EnvironmentIDs) == 0 {
+		return db
+	}
+	return db.Where("id IN ?", []uuid.UUID(key.EnvironmentIDs))
+}
+
+// the key must be scoped to every environment the environment inherits from
+func (key *APIKey) CanReadEnvironmentSecrets(db *gorm.DB, environment *Environment) bool {
+	if len(key.EnvironmentIDs) == 0 {
+		return true
+	}
+
+	lineage, err := GetEnvironmentLineage(db, environment)
+	if err != nil || len(lineage) == 0 {
+		return false
+	}
+
+	scope := make(map[uuid.UUID]bool, len(key.EnvironmentIDs))
+	for _, id := range key.EnvironmentIDs {
+		scope[id] = true
+	}
+
+	for _, id := range lineage {
+		if !scope[id] {
+			return false
+		}
+	}
+
+	return true
+}
+
+type ReqCreateAPIKey struct {
+	Name           string     `json:"name" validate:"required,name,lte=255"`
+	ProjectIDs     []string   `json:"projectIDs" validate:"omitempty,dive,uuid"`
+	EnvironmentIDs []string   `json:"environmentIDs" validate:"omitempty,dive,uuid"`
+	Access         string     `json:"access" validate:"required,oneof=read write"`
+	ExpiresAt      *time.Time `json:"expiresAt"`
+}
@@ -0,0 +1,235 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const (
+	AuditUserRegister        = "user.register"
+	AuditUserLogin           = "user.login"
+	AuditUserVerify          = "user.verify"
+	AuditUserUpdatePassword  = "user.update_password"
+	AuditUserUpdateName      = "user.update_name"
+	AuditUserUpdatePublicKey = "user.update_public_key"
+	AuditUserDelete          = "user.delete"
+	AuditAPIKeyCreate        = "apikey.create"
+	AuditAPIKeyRevoke        = "apikey.revoke"
+	AuditOrganizationCreate  = "organization.create"
+	AuditOrganizationUpdate  = "organization.update"
+	AuditOrganizationDelete  = "organization.delete"
+	AuditMemberInvite        = "member.invite"
+	AuditMemberJoin          = "member.join"
+	AuditMemberRemove        = "member.remove"
+	AuditMemberUpdateRole    = "member.update_role"
+	AuditProjectCreate       = "project.create"
+	AuditProjectUpdate       = "project.update"
+	AuditProjectDelete       = "project.delete"
+	AuditProjectShareKey     = "project.share_key"
+	AuditEnvironmentCreate   = "environment.create"
+	AuditEnvironmentUpdate   = "environment.update"
+	AuditEnvironmentDelete   = "environment.delete"
+	AuditEnvironmentRestrict = "environment.restrict"
+	AuditEnvironmentClone    = "environment.clone"
+	AuditEnvironmentPromote  = "environment.promote"
+	AuditSecretCreate        = "secret.create"
+	AuditSecretImport        = "secret.import"
+	AuditSecretUpdate        = "secret.update"
+	AuditSecretDelete        = "secret.delete"
+	AuditSecretRestore       = "secret.restore"
+	AuditSecretRead          = "secret.read"
+	AuditSecretRevisionRead  = "secret.revision_read"
+	AuditSecretCLIRead       = "secret.cli_read"
+	AuditSecretDiff          = "secret.diff"
+)
+
+var ErrAuditEventImmutable = errors.New("audit events can't be updated or deleted")
+
+const auditChainLockKey = 7_163_021
+
+// the project, organization and actor IDs don't reference their tables so that events outlive what they describe;
+// each event's hash is an HMAC of the previous event's hash and its own contents
+type AuditEvent struct {
+	ID             uuid.UUID                      `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	ChainID        uuid.UUID                      `gorm:"type:uuid;not null;uniqueIndex:audit_chain_sequence_index,priority:1" json:"chainID"`
+	Sequence       int64                          `gorm:"not null;uniqueIndex:audit_chain_sequence_index,priority:2" json:"sequence"`
+	ActorUserID    uuid.UUID                      `gorm:"type:uuid;index" json:"actorUserID"`
+	APIKeyID       *uuid.UUID                     `gorm:"type:uuid" json:"apiKeyID"`
+	Action         string                         `gorm:"type:varchar(64);index;not null" json:"action"`
+	OrganizationID *uuid.UUID                     `gorm:"type:uuid;index" json:"organizationID"`
+	ProjectID      *uuid.UUID                     `gorm:"type:uuid;index:audit_project_index" json:"projectID"`
+	ResourceIDs    datatypes.JSONSlice[uuid.UUID] `json:"resourceIDs"`
+	IP             string                         `gorm:"type:varchar(64)" json:"ip"`
+	UserAgent      string                         `gorm:"type:text" json:"userAgent"`
+	CreatedAt      time.Time                      `gorm:"index:audit_project_index" json:"createdAt"`
+	PrevHash       []byte                         `json:"prevHash"`
+	Hash           []byte                         `gorm:"not null" json:"hash"`
+}
+
+func (event *AuditEvent) BeforeUpdate(tx *gorm.DB) (err error) {
+	return ErrAuditEventImmutable
+}
+
+func (event *AuditEvent) BeforeDelete(tx *gorm.DB) (err error) {
+	return ErrAuditEventImmutable
+}
+
+func (event *AuditEvent) chain() uuid.UUID {
+	if event.ProjectID != nil {
+		return *event.ProjectID
+	}
+	if event.OrganizationID != nil {
+		return *event.OrganizationID
+	}
+	return uuid.Nil
+}
+
+// created_at is truncated to postgres' microsecond precision; the nil chain leaves out the chain ID
+func (event *AuditEvent) chainContents() []byte {
+	optionalID := func(id *uuid.UUID) string {
+		if id == nil {
+			return ""
+		}
+		return id.String()
+	}
+
+	resourceIDs := make([]string, 0, len(event.ResourceIDs))
+	for _, id := range event.ResourceIDs {
+		resourceIDs = append(resourceIDs, id.String())
+	}
+
+	contents := []string{
+		event.ID.String(),
+		strconv.FormatInt(event.Sequence, 10),
+		event.ActorUserID.String(),
+		optionalID(event.APIKeyID),
+		event.Action,
+		optionalID(event.OrganizationID),
+		optionalID(event.ProjectID),
+		strings.Join(resourceIDs, ","),
+		event.IP,
+		event.UserAgent,
+		event.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+	}
+	if event.ChainID != uuid.Nil {
+		contents = append(contents, event.ChainID.String())
+	}
+
+	return []byte(strings.Join(contents, "\n"))
+}
+
+// the chain's advisory lock is held until the surrounding transaction ends
+func AppendAuditEvent(db *gorm.DB, event *AuditEvent) error {
+	event.ChainID = event.chain()
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(
+			"SELECT pg_advisory_xact_lock(?, hashtext(?))", auditChainLockKey, event.ChainID.String(),
+		).Error; err != nil {
+			return err
+		}
+
+		var prevEvent AuditEvent
+		if err := tx.Where("chain_id=?", event.ChainID).Order("sequence DESC").Limit(1).Find(&prevEvent).Error; err != nil {
+			return err
+		}
+
+		if event.ID == uuid.Nil {
+			event.ID = uuid.New()
+		}
+		event.Sequence = prevEvent.Sequence + 1
+		event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+		event.PrevHash = prevEvent.Hash
+		event.Hash = utils.HashAuditEvent(event.PrevHash, event.chainContents())
+
+		return tx.Create(event).Error
+	})
+}
+
+type AuditChainBreak struct {
+	Sequence int64     `json:"sequence"`
+	EventID  uuid.UUID `json:"eventID"`
+	Reason   string    `json:"reason"`
+}
+
+type AuditChainResult struct {
+	ChainID  uuid.UUID        `json:"chainID"`
+	Valid    bool             `json:"valid"`
+	Checked  int64            `json:"checked"`
+	HeadHash []byte           `json:"headHash"`
+	Break    *AuditChainBreak `json:"break"`
+}
+
+func GetAuditChainIDs(db *gorm.DB) ([]uuid.UUID, error) {
+	var chainIDs []uuid.UUID
+	err := db.Model(&AuditEvent{}).Distinct().Order("chain_id").Pluck("chain_id", &chainIDs).Error
+	return chainIDs, err
+}
+
+// removals from the end of the chain can only be detected by recording the head hash externally
+func VerifyAuditChain(db *gorm.DB, chainID uuid.UUID, batchSize int) (AuditChainResult, error) {
+	result := AuditChainResult{ChainID: chainID}
+	var prevEvent AuditEvent
+
+	for {
+		var events []AuditEvent
+		if err := db.Where(
+			"chain_id=? AND sequence > ?", chainID, prevEvent.Sequence,
+		).Order("sequence").Limit(batchSize).Find(&events).Error; err != nil {
+			return result, err
+		}
+
+		for i := range events {
+			event := &events[i]
+
+			reason := ""
+			switch {
+			case event.Sequence != prevEvent.Sequence+1:
+				reason = "the event doesn't directly follow the previous event, one or more events have been removed"
+			case !bytes.Equal(event.PrevHash, prevEvent.Hash):
+				reason = "the event's previous hash doesn't match the hash of the previous event"
+			case !utils.CompareAuditHash(event.Hash, utils.HashAuditEvent(event.PrevHash, event.chainContents())):
+				reason = "the event's hash doesn't match its contents, the event has been altered"
+			}
+
+			if len(reason) > 0 {
+				result.Break = &AuditChainBreak{Sequence: event.Sequence, EventID: event.ID, Reason: reason}
+				result.HeadHash = prevEvent.Hash
+				return result, nil
+			}
+
+			result.Checked++
+			prevEvent = *event
+		}
+
+		if len(events) < batchSize {
+			break
+		}
+	}
+
+	result.Valid = true
+	result.HeadHash = prevEvent.Hash
+	return result, nil
+}
+
+func AuditResources(ids ...uuid.UUID) datatypes.JSONSlice[uuid.UUID] {
+	return datatypes.NewJSONSlice(ids)
+}
+
+type ReqGetAuditEvents struct {
+	Action     string `query:"action" validate:"omitempty,lte=64"`
+	ActorID    string `query:"actorID" validate:"omitempty,uuid"`
+	ResourceID string `query:"resourceID" validate:"omitempty,uuid"`
+	From       string `query:"from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	To         string `query:"to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Page       int    `query:"page" validate:"omitempty,gte=1"`
+	Limit      int    `query:"limit" validate:"omitempty,gte=1,lte=100"`
+}
@@ -1,25 +1,98 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/gorm"
 )
 
+const EnvironmentLimit = 10
+
 type Environment struct {
 	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
-	ProjectID uuid.UUID `gorm:"type:uuid;index:env_index" json:"projectID"`
+	ProjectID uuid.UUID `gorm:"type:uuid;uniqueIndex:env_index" json:"projectID"`
 	Project   Project   `gorm:"foreignKey:ProjectID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
-	UserID    uuid.UUID `gorm:"type:uuid;index:env_index" json:"userID"`
-	User      User      `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
-	Name      string    `gorm:"type:varchar(255);index:env_index;not null" json:"name"`
+	UserID    uuid.UUID `gorm:"type:uuid" json:"userID"`
+	Name      string    `gorm:"type:varchar(255);uniqueIndex:env_index;not null" json:"name"`
+	Version   int       `gorm:"not null;default:1" json:"version"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
+	// deleting the parent orphans its children
+	ParentID *uuid.UUID   `gorm:"type:uuid;index" json:"parentID"`
+	Parent   *Environment `gorm:"foreignKey:ParentID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;" json:"-"`
+}
+
+// nearest first
+func GetEnvironmentLineage(db *gorm.DB, environment *Environment) ([]uuid.UUID, error) {
+	var lineage []uuid.UUID
+	if err := db.Raw(utils.FindEnvironmentLineageQuery, environment.ProjectID, environment.ID).Scan(&lineage).Error; err != nil {
+		return nil, err
+	}
+
+	return lineage, nil
+}
+
+// built from the secrets' versions so that nothing has to be decrypted
+func GetEnvironmentSecretsETag(db *gorm.DB, environment *Environment, variants ...string) (string, error) {
+	var secrets []SecretResult
+	if err := db.Raw(
+		utils.FindSecretVersionsByEnvIDQuery, environment.ProjectID, environment.ID,
+	).Scan(&secrets).Error; err != nil {
+		return "", err
+	}
+
+	parts := append([]string{}, variants...)
+	for _, secret := range secrets {
+		parts = append(parts, fmt.Sprintf("%s:%d:%s", secret.ID, secret.Version, secret.SourceEnvironmentID))
+	}
+
+	return utils.HashETag(parts...), nil
+}
+
+func IsEnvironmentAncestor(db *gorm.DB, environment *Environment, parent *Environment) (bool, error) {
+	lineage, err := GetEnvironmentLineage(db, parent)
+	if err != nil {
+		return false, err
+	}
+
+	for _, id := range lineage {
+		if id == environment.ID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func GetEnvNames(environments *[]Environment) string {
+	var envNames string
+	for _, env := range *environments {
+		if len(envNames) == 0 {
+			envNames += env.Name
+		} else {
+			envNames += fmt.Sprintf(", %s", env.Name)
+		}
+	}
+
+	return envNames
+}
+
+func GetEnvIDs(environments *[]Environment) []uuid.UUID {
+	environmentIDs := make([]uuid.UUID, 0, len(*environments))
+	for _, env := range *environments {
+		environmentIDs = append(environmentIDs, env.ID)
+	}
+
+	return environmentIDs
 }
 
 type ReqCreateEnv struct {
 	Name      string `json:"name" validate:"required,name,lte=255"`
 	ProjectID string `json:"projectID" validate:"uuid"`
+	ParentID  string `json:"parentID" validate:"omitempty,uuid"`
 }
 
 type ReqUpdateEnv struct {
@@ -27,3 +100,20 @@ type ReqUpdateEnv struct {
 	ProjectID   string `json:"projectID" validate:"uuid"`
 	UpdatedName string `json:"updatedName" validate:"required,name,lte=255"`
 }
+
+type ReqCloneEnv struct {
+	ID   string `json:"id" validate:"required,uuid"`
+	Name string `json:"name" validate:"required,name,lte=255"`
+}
+
+type ReqPromoteEnv struct {
+	SourceID string   `json:"sourceID" validate:"required,uuid"`
+	Target   string   `json:"target" validate:"required,name,lte=255"`
+	Keys     []string `json:"keys" validate:"required,min=1,dive,gte=2,lte=255"`
+}
+
+// an empty parent ID stops the environment from inheriting
+type ReqUpdateEnvParent struct {
+	ID       string `json:"id" validate:"required,uuid"`
+	ParentID string `json:"parentID" validate:"omitempty,uuid"`
+}
@@ -0,0 +1,276 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/gorm"
+)
+
+// the key column is kept in sync by the database so a key can only be attached to an environment once
+type EnvironmentSecret struct {
+	SecretID      uuid.UUID   `gorm:"type:uuid;primaryKey" json:"secretID"`
+	Secret        Secret      `gorm:"foreignKey:SecretID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	EnvironmentID uuid.UUID   `gorm:"type:uuid;primaryKey" json:"environmentID"`
+	Environment   Environment `gorm:"foreignKey:EnvironmentID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Value         []byte      `json:"-"`
+	Nonce         []byte      `json:"-"`
+	SealVersion   int         `gorm:"not null;default:0" json:"-"`
+}
+
+func (EnvironmentSecret) TableName() string {
+	return "environment_secrets"
+}
+
+type EnvironmentSecretValue struct {
+	EnvironmentID uuid.UUID `json:"environmentID"`
+	Value         []byte    `json:"value"`
+	Nonce         []byte    `json:"nonce"`
+	SealVersion   int       `json:"sealVersion"`
+}
+
+func GetEnvironmentSecretValues(db *gorm.DB, secretID uuid.UUID) ([]EnvironmentSecretValue, error) {
+	values := []EnvironmentSecretValue{}
+	err := db.Model(&EnvironmentSecret{}).Where("secret_id=? AND value IS NOT NULL", secretID).Find(&values).Error
+	return values, err
+}
+
+func (member *OrganizationMember) OpenEnvironmentSecretValues(
+	db *gorm.DB, project *Project, secretID uuid.UUID, values []EnvironmentSecretValue,
+) (map[uuid.UUID]string, error) {
+	environmentIDs := make([]uuid.UUID, 0, len(values))
+	for _, value := range values {
+		environmentIDs = append(environmentIDs, value.EnvironmentID)
+	}
+
+	access, err := member.EnvironmentAccess(db, environmentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	opened := make(map[uuid.UUID]string, len(values))
+	for _, value := range values {
+		if !utils.HasAccess(access[value.EnvironmentID], utils.AccessRead) {
+			continue
+		}
+
+		plaintext, err := project.OpenEnvironmentSecretValue(
+			secretID, value.EnvironmentID, value.SealVersion, value.Value, value.Nonce,
+		)
+		if err != nil {
+			return nil, err
+		}
+		opened[value.EnvironmentID] = plaintext
+	}
+
+	return opened, nil
+}
+
+// values for environments the secret no longer belongs to are left out
+func RestoreEnvironmentSecretValues(db *gorm.DB, secretID uuid.UUID, values []EnvironmentSecretValue) error {
+	if err := db.Model(&EnvironmentSecret{}).Where("secret_id=?", secretID).UpdateColumns(
+		map[string]interface{}{"value": nil, "nonce": nil, "seal_version": 0},
+	).Error; err != nil {
+		return err
+	}
+
+	for _, value := range values {
+		if err := setSealedEnvironmentSecretValue(db, EnvironmentSecret{
+			EnvironmentID: value.EnvironmentID,
+			SecretID:      secretID,
+			Value:         value.Value,
+			Nonce:         value.Nonce,
+			SealVersion:   value.SealVersion,
+		}); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// the secret must already be attached to the environment
+func SetEnvironmentSecretValue(db *gorm.DB, project *Project, secretID uuid.UUID, environmentID uuid.UUID, value []byte) error {
+	sealedValue, nonce, sealVersion, err := project.SealEnvironmentSecretValue(secretID, environmentID, value)
+	if err != nil {
+		return err
+	}
+
+	return setSealedEnvironmentSecretValue(db, EnvironmentSecret{
+		EnvironmentID: environmentID,
+		SecretID:      secretID,
+		Value:         sealedValue,
+		Nonce:         nonce,
+		SealVersion:   sealVersion,
+	})
+}
+
+func setSealedEnvironmentSecretValue(db *gorm.DB, value EnvironmentSecret) error {
+	result := db.Model(&EnvironmentSecret{}).Where(
+		"secret_id=? AND environment_id=?", value.SecretID, value.EnvironmentID,
+	).UpdateColumns(map[string]interface{}{
+		"value":        value.Value,
+		"nonce":        value.Nonce,
+		"seal_version": value.SealVersion,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// values are bound to their environment, so they're opened and sealed again
+func CopyEnvironmentSecretValues(db *gorm.DB, project *Project, from uuid.UUID, to uuid.UUID) error {
+	var values []EnvironmentSecret
+	if err := db.Where("environment_id=? AND value IS NOT NULL", from).Find(&values).Error; err != nil {
+		return err
+	}
+
+	for _, value := range values {
+		if project.EndToEnd {
+			value.EnvironmentID = to
+			if err := setSealedEnvironmentSecretValue(db, value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		plaintext, err := project.OpenEnvironmentSecretValue(value.SecretID, from, value.SealVersion, value.Value, value.Nonce)
+		if err != nil {
+			return err
+		}
+
+		if err := SetEnvironmentSecretValue(db, project, value.SecretID, to, []byte(plaintext)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ClearEnvironmentSecretValues(db *gorm.DB, secretID uuid.UUID, environmentIDs []uuid.UUID) error {
+	return db.Model(&EnvironmentSecret{}).Where(
+		"secret_id=? AND environment_id IN ?", secretID, environmentIDs,
+	).UpdateColumns(map[string]interface{}{"value": nil, "nonce": nil, "seal_version": 0}).Error
+}
+
+// done before the key changes so it's only held unique within the remaining environments
+func DetachSecretEnvironments(db *gorm.DB, secretID uuid.UUID, environmentIDs []uuid.UUID) error {
+	query := db.Where("secret_id=?", secretID)
+	if len(environmentIDs) > 0 {
+		query = query.Where("environment_id NOT IN ?", environmentIDs)
+	}
+
+	return query.Delete(&EnvironmentSecret{}).Error
+}
+
+// merged keys no longer match, so calling it until it returns 0 merges every key
+func MergeSecretsByKey(db *gorm.DB, batchSize int) (int64, error) {
+	var merged int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var keys []struct {
+			ProjectID uuid.UUID
+			Key       string
+		}
+		if err := tx.Model(&Secret{}).Select(
+			"project_id, key",
+		).Group("project_id, key").Having("COUNT(*) > 1").Limit(batchSize).Scan(&keys).Error; err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			project, err := GetSecretProject(tx, key.ProjectID)
+			if err != nil {
+				return fmt.Errorf("unable to find the %s project: %w", key.ProjectID, err)
+			}
+
+			var secrets []Secret
+			if err := tx.Preload("Environments").Order("created_at").Find(
+				&secrets, "project_id=? AND key=?", key.ProjectID, key.Key,
+			).Error; err != nil {
+				return err
+			}
+
+			mergedSecret := secrets[0]
+			for _, secret := range secrets[1:] {
+				values, err := GetEnvironmentSecretValues(tx, secret.ID)
+				if err != nil {
+					return err
+				}
+
+				environmentValues := make(map[uuid.UUID]EnvironmentSecretValue, len(values))
+				for _, value := range values {
+					environmentValues[value.EnvironmentID] = value
+				}
+
+				environmentSecrets := make([]EnvironmentSecret, 0, len(secret.Environments))
+				for _, env := range secret.Environments {
+					environmentSecret := EnvironmentSecret{EnvironmentID: env.ID, SecretID: mergedSecret.ID}
+
+					// end-to-end encrypted values are sealed by the client
+					if project.EndToEnd {
+						environmentSecret.Value, environmentSecret.Nonce = secret.Value, []byte{}
+						if value, ok := environmentValues[env.ID]; ok {
+							environmentSecret.Value = value.Value
+						}
+					} else {
+						var plaintext string
+						if value, ok := environmentValues[env.ID]; ok {
+							plaintext, err = project.OpenEnvironmentSecretValue(
+								secret.ID, env.ID, value.SealVersion, value.Value, value.Nonce,
+							)
+						} else {
+							plaintext, err = project.OpenSecretValue(
+								secret.ID, secret.Key, secret.SealVersion, secret.Value, secret.Nonce,
+							)
+						}
+						if err != nil {
+							return fmt.Errorf("unable to open the value of the %s secret: %w", secret.ID, err)
+						}
+
+						environmentSecret.Value, environmentSecret.Nonce, environmentSecret.SealVersion, err =
+							project.SealEnvironmentSecretValue(mergedSecret.ID, env.ID, []byte(plaintext))
+						if err != nil {
+							return err
+						}
+					}
+
+					environmentSecrets = append(environmentSecrets, environmentSecret)
+					mergedSecret.Environments = append(mergedSecret.Environments, env)
+				}
+
+				// the secret is deleted first since its key can only be attached to each environment once
+				if err := CreateSecretDeletionRevision(tx, &secret); err != nil {
+					return err
+				}
+
+				if err := tx.Delete(&secret).Error; err != nil {
+					return err
+				}
+
+				for _, environmentSecret := range environmentSecrets {
+					if err := tx.Create(&environmentSecret).Error; err != nil {
+						return err
+					}
+				}
+			}
+
+			if err := CreateSecretRevision(tx, &mergedSecret); err != nil {
+				return err
+			}
+
+			merged++
+		}
+
+		return nil
+	})
+
+	return merged, err
+}
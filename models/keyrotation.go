@@ -0,0 +1,97 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	KeyRotationRunning   = "running"
+	KeyRotationCompleted = "completed"
+	KeyRotationFailed    = "failed"
+)
+
+// one job per master key, so an interrupted job resumes once that key is active again
+type KeyRotationJob struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	TargetKeyID string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"targetKeyID"`
+	Status      string     `gorm:"type:varchar(16);not null" json:"status"`
+	Total       int64      `gorm:"not null;default:0" json:"total"`
+	Rewrapped   int64      `gorm:"not null;default:0" json:"rewrapped"`
+	Error       string     `gorm:"type:text" json:"error"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	CompletedAt *time.Time `json:"completedAt"`
+}
+
+func NotWrappedBy(masterKeyID string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("master_key_id <> ? AND wrapped_data_key IS NOT NULL", masterKeyID)
+	}
+}
+
+// projects locked by another instance are skipped
+func RewrapProjectDataKeys(db *gorm.DB, batchSize int) (int64, error) {
+	var rewrapped int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var projects []Project
+		if err := tx.Select(
+			"id", "wrapped_data_key", "master_key_id",
+		).Scopes(
+			NotWrappedBy(utils.ActiveKeyProvider.KeyID()),
+		).Clauses(
+			clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"},
+		).Order("id").Limit(batchSize).Find(&projects).Error; err != nil {
+			return err
+		}
+
+		for _, project := range projects {
+			wrappedKey, keyID, err := utils.RewrapDataKey(project.MasterKeyID, project.WrappedDataKey)
+			if err != nil {
+				return fmt.Errorf("unable to re-wrap the data key of the %s project: %w", project.ID, err)
+			}
+
+			// the data key itself doesn't change, so the secrets don't need to be re-encrypted
+			if err := tx.Model(&Project{}).Where("id=?", project.ID).UpdateColumns(map[string]interface{}{
+				"wrapped_data_key": wrappedKey,
+				"master_key_id":    keyID,
+			}).Error; err != nil {
+				return err
+			}
+
+			rewrapped++
+		}
+
+		return nil
+	})
+
+	return rewrapped, err
+}
+
+type KeyRotationStatus struct {
+	ActiveKeyID string          `json:"activeKeyID"`
+	Remaining   int64           `json:"remaining"`
+	Job         *KeyRotationJob `json:"job"`
+}
+
+func GetKeyRotationStatus(db *gorm.DB, masterKeyID string) (KeyRotationStatus, error) {
+	status := KeyRotationStatus{ActiveKeyID: masterKeyID}
+	if err := db.Model(&Project{}).Scopes(NotWrappedBy(masterKeyID)).Count(&status.Remaining).Error; err != nil {
+		return status, err
+	}
+
+	var job KeyRotationJob
+	if err := db.Where(&KeyRotationJob{TargetKeyID: masterKeyID}).First(&job).Error; err == nil {
+		status.Job = &job
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return status, err
+	}
+
+	return status, nil
+}
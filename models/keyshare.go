@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// the project key sealed for a member's public key; the server can't open it
+type ProjectKeyShare struct {
+	ID             uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	ProjectID      uuid.UUID `gorm:"type:uuid;uniqueIndex:keyshare_index" json:"projectID"`
+	Project        Project   `gorm:"foreignKey:ProjectID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	UserID         uuid.UUID `gorm:"type:uuid;uniqueIndex:keyshare_index" json:"userID"`
+	User           User      `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	SharedByUserID uuid.UUID `gorm:"type:uuid" json:"sharedByUserID"`
+	EncryptedKey   []byte    `gorm:"not null" json:"encryptedKey"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+type MemberPublicKey struct {
+	UserID      uuid.UUID `json:"userID"`
+	Name        string    `json:"name"`
+	PublicKey   []byte    `json:"publicKey"`
+	HasKeyShare bool      `json:"hasKeyShare"`
+}
+
+type ReqUpdatePublicKey struct {
+	PublicKey string `json:"publicKey" validate:"required,base64,lte=64"`
+}
+
+type ReqUpdateKeyShare struct {
+	ProjectID    string `json:"projectID" validate:"required,uuid"`
+	UserID       string `json:"userID" validate:"required,uuid"`
+	EncryptedKey string `json:"encryptedKey" validate:"required,base64,lte=1024"`
+}
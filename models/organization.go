@@ -0,0 +1,115 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/gorm"
+)
+
+type Organization struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	Name      string    `gorm:"type:varchar(255);index:organization_index;not null" json:"name"`
+	OwnerID   uuid.UUID `gorm:"type:uuid;index:organization_index" json:"ownerID"`
+	Owner     User      `gorm:"foreignKey:OwnerID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Personal  bool      `gorm:"default:false" json:"personal"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type OrganizationMember struct {
+	ID             uuid.UUID    `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	OrganizationID uuid.UUID    `gorm:"type:uuid;uniqueIndex:member_index" json:"organizationID"`
+	Organization   Organization `gorm:"foreignKey:OrganizationID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	UserID         uuid.UUID    `gorm:"type:uuid;uniqueIndex:member_index" json:"userID"`
+	User           User         `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Role           string       `gorm:"type:varchar(16);not null;default:'developer'" json:"role"`
+	CreatedAt      time.Time    `json:"createdAt"`
+}
+
+type OrganizationInvite struct {
+	ID             uuid.UUID    `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	OrganizationID uuid.UUID    `gorm:"type:uuid;index:invite_index" json:"organizationID"`
+	Organization   Organization `gorm:"foreignKey:OrganizationID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Email          string       `gorm:"type:varchar(255);index:invite_index;not null" json:"email"`
+	InvitedByID    uuid.UUID    `gorm:"type:uuid" json:"invitedByID"`
+	Role           string       `gorm:"type:varchar(16);not null;default:'developer'" json:"role"`
+	CreatedAt      time.Time    `json:"createdAt"`
+}
+
+type OrganizationMemberResult struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"userID"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func CreatePersonalOrganization(tx *gorm.DB, user *User) error {
+	db := tx.Session(&gorm.Session{NewDB: true})
+
+	organization := Organization{Name: "personal", OwnerID: user.ID, Personal: true}
+	if err := db.Create(&organization).Error; err != nil {
+		return err
+	}
+
+	return db.Create(
+		&OrganizationMember{OrganizationID: organization.ID, UserID: user.ID, Role: utils.RoleOwner},
+	).Error
+}
+
+func memberOrganizationIDs(db *gorm.DB, userID uuid.UUID) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Model(&OrganizationMember{}).Select("organization_id").Where("user_id=?", userID)
+}
+
+func memberProjectIDs(db *gorm.DB, userID uuid.UUID) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Model(&Project{}).Select("id").Where(
+		"organization_id IN (?)", memberOrganizationIDs(db, userID),
+	)
+}
+
+func MemberOrganizations(userID uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN (?)", memberOrganizationIDs(db, userID))
+	}
+}
+
+func MemberOrganizationResources(userID uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("organization_id IN (?)", memberOrganizationIDs(db, userID))
+	}
+}
+
+func MemberProjects(userID uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("organization_id IN (?)", memberOrganizationIDs(db, userID))
+	}
+}
+
+func MemberProjectResources(userID uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("project_id IN (?)", memberProjectIDs(db, userID))
+	}
+}
+
+func InOrganization(organizationID string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if parsedID, err := utils.ParseUUID(organizationID); err == nil {
+			return db.Where("organization_id=?", parsedID)
+		}
+		return db
+	}
+}
+
+type ReqUpdateOrganization struct {
+	ID          string `json:"id" validate:"required,uuid"`
+	UpdatedName string `json:"updatedName" validate:"required,name,lte=255"`
+}
+
+type ReqInviteMember struct {
+	OrganizationID string `json:"organizationID" validate:"required,uuid"`
+	Email          string `json:"email" validate:"required,email,lte=255"`
+	Role           string `json:"role" validate:"omitempty,oneof=admin developer viewer"`
+}
@@ -1,18 +1,160 @@
 package models
 
 import (
+	"encoding/base64"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/gorm"
 )
 
+var ErrProjectDataKeyDestroyed = errors.New("the project's data key has been destroyed, so its secrets can't be decrypted")
+
+var ErrProjectEndToEnd = errors.New("the project is end-to-end encrypted, so the server doesn't hold a key for its secrets")
+
 type Project struct {
-	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
-	Name      string    `gorm:"type:varchar(255);index:project_index;not null" json:"name"`
-	UserID    uuid.UUID `gorm:"type:uuid;index:project_index" json:"userID"`
-	User      User      `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID             uuid.UUID    `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	Name           string       `gorm:"type:varchar(255);uniqueIndex:project_index;not null" json:"name"`
+	OrganizationID uuid.UUID    `gorm:"type:uuid;uniqueIndex:project_index" json:"organizationID"`
+	Organization   Organization `gorm:"foreignKey:OrganizationID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	UserID         uuid.UUID    `gorm:"type:uuid" json:"userID"`
+	// can't be changed once the project has been created
+	EndToEnd bool `gorm:"not null;default:false" json:"endToEnd"`
+	// destroying it crypto-shreds the project
+	WrappedDataKey []byte    `json:"-"`
+	MasterKeyID    string    `gorm:"type:varchar(64);index" json:"-"`
+	Version        int       `gorm:"not null;default:1" json:"version"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+func (project *Project) BeforeCreate(tx *gorm.DB) (err error) {
+	if project.EndToEnd {
+		return nil
+	}
+
+	_, wrappedKey, keyID, err := utils.CreateDataKey()
+	if err != nil {
+		return err
+	}
+
+	tx.Statement.SetColumn("WrappedDataKey", wrappedKey)
+	tx.Statement.SetColumn("MasterKeyID", keyID)
+
+	return nil
+}
+
+func (project *Project) UnwrapDataKey() ([]byte, error) {
+	if project.EndToEnd {
+		return nil, ErrProjectEndToEnd
+	}
+
+	if len(project.WrappedDataKey) == 0 {
+		return nil, ErrProjectDataKeyDestroyed
+	}
+	return utils.UnwrapDataKey(project.MasterKeyID, project.WrappedDataKey)
+}
+
+func GetSecretProject(db *gorm.DB, projectID uuid.UUID) (Project, error) {
+	var project Project
+	err := db.Select("id", "end_to_end", "wrapped_data_key", "master_key_id").First(&project, "id=?", projectID).Error
+	return project, err
+}
+
+func GetProjectDataKey(db *gorm.DB, projectID uuid.UUID) ([]byte, error) {
+	project, err := GetSecretProject(db, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return project.UnwrapDataKey()
+}
+
+// end-to-end encrypted values are base64 encoded ciphertexts
+func (project *Project) ParseSecretValue(value string) ([]byte, error) {
+	if !project.EndToEnd {
+		return []byte(value), nil
+	}
+	return base64.StdEncoding.DecodeString(value)
+}
+
+// the value is bound to the secret; end-to-end encrypted values are stored as they are
+func (project *Project) SealSecretValue(secretID uuid.UUID, key string, value []byte) ([]byte, []byte, int, error) {
+	if project.EndToEnd {
+		return value, []byte{}, 0, nil
+	}
+
+	dataKey, err := project.UnwrapDataKey()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	sealedValue, nonce, err := utils.CreateEncryptedSecretValue(
+		dataKey, value, utils.SecretAssociatedData(utils.SecretSealVersion, secretID, project.ID, key),
+	)
+	return sealedValue, nonce, utils.SecretSealVersion, err
+}
+
+func (project *Project) OpenSecretValue(secretID uuid.UUID, key string, sealVersion int, value []byte, nonce []byte) (string, error) {
+	if project.EndToEnd {
+		return base64.StdEncoding.EncodeToString(value), nil
+	}
+
+	if sealVersion == 0 {
+		return "", utils.ErrUnboundSecretValue
+	}
+
+	dataKey, err := project.UnwrapDataKey()
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := utils.DecryptSecretValue(
+		dataKey, value, nonce, utils.SecretAssociatedData(sealVersion, secretID, project.ID, key),
+	)
+	return string(plaintext), err
+}
+
+func (project *Project) SealEnvironmentSecretValue(secretID uuid.UUID, environmentID uuid.UUID, value []byte) ([]byte, []byte, int, error) {
+	if project.EndToEnd {
+		return value, []byte{}, 0, nil
+	}
+
+	dataKey, err := project.UnwrapDataKey()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	sealedValue, nonce, err := utils.CreateEncryptedSecretValue(
+		dataKey, value, utils.SecretEnvironmentAssociatedData(utils.SecretSealVersion, secretID, environmentID, project.ID),
+	)
+	return sealedValue, nonce, utils.SecretSealVersion, err
+}
+
+func (project *Project) OpenEnvironmentSecretValue(secretID uuid.UUID, environmentID uuid.UUID, sealVersion int, value []byte, nonce []byte) (string, error) {
+	if project.EndToEnd {
+		return base64.StdEncoding.EncodeToString(value), nil
+	}
+
+	dataKey, err := project.UnwrapDataKey()
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := utils.DecryptSecretValue(
+		dataKey, value, nonce, utils.SecretEnvironmentAssociatedData(sealVersion, secretID, environmentID, project.ID),
+	)
+	return string(plaintext), err
+}
+
+func (project *Project) OpenSecretResult(secret *SecretResult) (string, error) {
+	if secret.ValueEnvironmentID != nil {
+		return project.OpenEnvironmentSecretValue(
+			secret.ID, *secret.ValueEnvironmentID, secret.SealVersion, secret.Value, secret.Nonce,
+		)
+	}
+	return project.OpenSecretValue(secret.ID, secret.Key, secret.SealVersion, secret.Value, secret.Nonce)
 }
 
 type ReqProject struct {
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/gorm"
+)
+
+// the values are still unbound, which ResealSecretValues then takes care of
+func CreateLegacyProjectDataKeys(db *gorm.DB, batchSize int) (int64, error) {
+	var created int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// crypto-shredded projects also have no wrapped key, but their values are already bound
+		var projects []Project
+		if err := tx.Select("id").Where(
+			"wrapped_data_key IS NULL AND end_to_end = ?", false,
+		).Where(
+			"NOT EXISTS (SELECT 1 FROM secrets WHERE secrets.project_id = projects.id AND secrets.seal_version > 0)",
+		).Where(
+			"NOT EXISTS (SELECT 1 FROM secret_revisions WHERE secret_revisions.project_id = projects.id AND secret_revisions.seal_version > 0)",
+		).Order("id").Limit(batchSize).Find(&projects).Error; err != nil {
+			return err
+		}
+
+		for _, project := range projects {
+			dataKey, wrappedKey, keyID, err := utils.CreateDataKey()
+			if err != nil {
+				return fmt.Errorf("unable to create a data key for the %s project: %w", project.ID, err)
+			}
+
+			var secrets []Secret
+			if err := tx.Select("id", "value", "nonce").Where("project_id=?", project.ID).Find(&secrets).Error; err != nil {
+				return err
+			}
+
+			for _, secret := range secrets {
+				value, nonce, err := resealMasterKeyValue(dataKey, secret.Value, secret.Nonce)
+				if err != nil {
+					return fmt.Errorf("unable to re-seal the value of the %s secret: %w", secret.ID, err)
+				}
+
+				if err := tx.Model(&Secret{}).Where("id=?", secret.ID).UpdateColumns(map[string]interface{}{
+					"value": value, "nonce": nonce,
+				}).Error; err != nil {
+					return err
+				}
+			}
+
+			var revisions []SecretRevision
+			if err := tx.Select("id", "value", "nonce").Where("project_id=?", project.ID).Find(&revisions).Error; err != nil {
+				return err
+			}
+
+			for _, revision := range revisions {
+				value, nonce, err := resealMasterKeyValue(dataKey, revision.Value, revision.Nonce)
+				if err != nil {
+					return fmt.Errorf("unable to re-seal the value of the %s secret revision: %w", revision.ID, err)
+				}
+
+				if err := tx.Model(&SecretRevision{}).Where("id=?", revision.ID).UpdateColumns(map[string]interface{}{
+					"value": value, "nonce": nonce,
+				}).Error; err != nil {
+					return err
+				}
+			}
+
+			if err := tx.Model(&Project{}).Where("id=?", project.ID).UpdateColumns(map[string]interface{}{
+				"wrapped_data_key": wrappedKey, "master_key_id": keyID,
+			}).Error; err != nil {
+				return err
+			}
+
+			created++
+		}
+
+		return nil
+	})
+
+	return created, err
+}
+
+// re-sealed rows no longer match, so calling it until it returns 0 re-seals every row
+func ResealSecretValues(db *gorm.DB, batchSize int) (int64, error) {
+	var resealed int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		dataKeys := make(map[uuid.UUID][]byte)
+		getDataKey := func(projectID uuid.UUID) ([]byte, error) {
+			if dataKey, ok := dataKeys[projectID]; ok {
+				return dataKey, nil
+			}
+
+			dataKey, err := GetProjectDataKey(tx, projectID)
+			if err != nil {
+				return nil, fmt.Errorf("unable to resolve the data key of the %s project: %w", projectID, err)
+			}
+
+			dataKeys[projectID] = dataKey
+			return dataKey, nil
+		}
+
+		// end-to-end encrypted values are sealed by the client
+		serverSealedProjects := func() *gorm.DB {
+			return tx.Model(&Project{}).Select("id").Where("end_to_end = ?", false)
+		}
+
+		var secrets []Secret
+		if err := tx.Where(
+			"seal_version = 0 AND project_id IN (?)", serverSealedProjects(),
+		).Order("id").Limit(batchSize).Find(&secrets).Error; err != nil {
+			return err
+		}
+
+		for _, secret := range secrets {
+			dataKey, err := getDataKey(secret.ProjectID)
+			if err != nil {
+				return err
+			}
+
+			value, nonce, err := reseal(dataKey, secret.Value, secret.Nonce, utils.SecretAssociatedData(utils.SecretSealVersion, secret.ID, secret.ProjectID, secret.Key))
+			if err != nil {
+				return fmt.Errorf("unable to re-seal the value of the %s secret: %w", secret.ID, err)
+			}
+
+			if err := tx.Model(&Secret{}).Where("id=?", secret.ID).UpdateColumns(map[string]interface{}{
+				"value": value, "nonce": nonce, "seal_version": utils.SecretSealVersion,
+			}).Error; err != nil {
+				return err
+			}
+
+			resealed++
+		}
+
+		if resealed > 0 {
+			return nil
+		}
+
+		// deleted secrets can still be restored from their revisions
+		var revisions []SecretRevision
+		if err := tx.Where(
+			"seal_version = 0 AND project_id IN (?)", serverSealedProjects(),
+		).Order("id").Limit(batchSize).Find(&revisions).Error; err != nil {
+			return err
+		}
+
+		for _, revision := range revisions {
+			dataKey, err := getDataKey(revision.ProjectID)
+			if err != nil {
+				return err
+			}
+
+			value, nonce, err := reseal(dataKey, revision.Value, revision.Nonce, utils.SecretAssociatedData(utils.SecretSealVersion, revision.SecretID, revision.ProjectID, revision.Key))
+			if err != nil {
+				return fmt.Errorf("unable to re-seal the value of the %s secret revision: %w", revision.ID, err)
+			}
+
+			// skips the immutable revision update hook
+			if err := tx.Model(&SecretRevision{}).Where("id=?", revision.ID).UpdateColumns(map[string]interface{}{
+				"value": value, "nonce": nonce, "seal_version": utils.SecretSealVersion,
+			}).Error; err != nil {
+				return err
+			}
+
+			resealed++
+		}
+
+		return nil
+	})
+
+	return resealed, err
+}
+
+func reseal(dataKey []byte, value []byte, nonce []byte, associatedData []byte) ([]byte, []byte, error) {
+	plaintext, err := utils.DecryptSecretValue(dataKey, value, nonce, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return utils.CreateEncryptedSecretValue(dataKey, plaintext, associatedData)
+}
+
+func resealMasterKeyValue(dataKey []byte, value []byte, nonce []byte) ([]byte, []byte, error) {
+	plaintext, err := utils.OpenMasterKeySealedValue(value, nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	return utils.CreateEncryptedSecretValue(dataKey, plaintext, nil)
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+type SecretRevision struct {
+	ID uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	// kept once the secret has been deleted so it can be restored
+	SecretID          uuid.UUID                                   `gorm:"type:uuid;index:revision_index" json:"secretID"`
+	ProjectID         uuid.UUID                                   `gorm:"type:uuid;index:revision_project_index;not null" json:"projectID"`
+	Project           Project                                     `gorm:"foreignKey:ProjectID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	UserID            uuid.UUID                                   `gorm:"type:uuid" json:"userID"`
+	Version           int                                         `gorm:"not null" json:"version"`
+	Key               string                                      `gorm:"type:varchar(255);not null" json:"key"`
+	Value             []byte                                      `gorm:"not null" json:"-"`
+	Nonce             []byte                                      `gorm:"not null" json:"-"`
+	SealVersion       int                                         `gorm:"not null;default:0" json:"-"`
+	EnvironmentIDs    datatypes.JSONSlice[uuid.UUID]              `json:"environmentIDs"`
+	Deleted           bool                                        `gorm:"not null;default:false" json:"deleted"`
+	CreatedAt         time.Time                                   `json:"createdAt"`
+	EnvironmentValues datatypes.JSONSlice[EnvironmentSecretValue] `json:"-"`
+}
+
+func (revision *SecretRevision) BeforeUpdate(tx *gorm.DB) (err error) {
+	return errors.New("secret revisions are immutable and cannot be updated")
+}
+
+func CreateSecretRevision(tx *gorm.DB, secret *Secret) error {
+	return createSecretRevision(tx, secret, false)
+}
+
+// must be recorded before the secret is deleted
+func CreateSecretDeletionRevision(tx *gorm.DB, secret *Secret) error {
+	return createSecretRevision(tx, secret, true)
+}
+
+func createSecretRevision(tx *gorm.DB, secret *Secret, deleted bool) error {
+	db := tx.Session(&gorm.Session{NewDB: true})
+
+	var latestVersion int
+	if err := db.Model(&SecretRevision{}).Select(
+		"COALESCE(MAX(version), 0)",
+	).Where("secret_id=?", secret.ID).Scan(&latestVersion).Error; err != nil {
+		return err
+	}
+
+	environmentIDs := make([]uuid.UUID, 0, len(secret.Environments))
+	for _, env := range secret.Environments {
+		environmentIDs = append(environmentIDs, env.ID)
+	}
+
+	environmentValues, err := GetEnvironmentSecretValues(db, secret.ID)
+	if err != nil {
+		return err
+	}
+
+	secret.Version = latestVersion + 1
+	if err := db.Model(&Secret{}).Where("id=?", secret.ID).UpdateColumn("version", secret.Version).Error; err != nil {
+		return err
+	}
+
+	return db.Create(&SecretRevision{
+		SecretID:          secret.ID,
+		ProjectID:         secret.ProjectID,
+		UserID:            secret.UserID,
+		Version:           secret.Version,
+		Key:               secret.Key,
+		Value:             secret.Value,
+		Nonce:             secret.Nonce,
+		SealVersion:       secret.SealVersion,
+		EnvironmentIDs:    datatypes.NewJSONSlice(environmentIDs),
+		EnvironmentValues: datatypes.NewJSONSlice(environmentValues),
+		Deleted:           deleted,
+	}).Error
+}
+
+// a deleted secret's revisions are readable through the environments it belonged to when deleted
+func GetSecretRevisionEnvironmentIDs(db *gorm.DB, secretID uuid.UUID) ([]uuid.UUID, error) {
+	var secret Secret
+	err := db.Preload("Environments").First(&secret, "id=?", secretID).Error
+	if err == nil {
+		return GetEnvIDs(&secret.Environments), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var latest SecretRevision
+	if err := db.Where(&SecretRevision{SecretID: secretID}).Order("version DESC").First(&latest).Error; err != nil {
+		return nil, err
+	}
+
+	return latest.EnvironmentIDs, nil
+}
+
+func (revision *SecretRevision) OpenValue(project *Project, environmentID uuid.UUID) (string, error) {
+	for _, value := range revision.EnvironmentValues {
+		if value.EnvironmentID == environmentID {
+			return project.OpenEnvironmentSecretValue(
+				revision.SecretID, environmentID, value.SealVersion, value.Value, value.Nonce,
+			)
+		}
+	}
+	return project.OpenSecretValue(revision.SecretID, revision.Key, revision.SealVersion, revision.Value, revision.Nonce)
+}
+
+// includes deleted secrets unless their latest revision at the time was their deletion
+func GetEnvironmentRevisionsAt(db *gorm.DB, environment *Environment, at time.Time) ([]SecretRevision, error) {
+	var latest []SecretRevision
+	if err := db.Raw(
+		`SELECT DISTINCT ON (r.secret_id) r.*
+		FROM secret_revisions r
+		WHERE r.project_id = ? AND r.created_at <= ?
+		ORDER BY r.secret_id, r.version DESC`,
+		environment.ProjectID, at,
+	).Scan(&latest).Error; err != nil {
+		return nil, err
+	}
+
+	revisions := make([]SecretRevision, 0, len(latest))
+	for _, revision := range latest {
+		if revision.Deleted {
+			continue
+		}
+
+		for _, id := range revision.EnvironmentIDs {
+			if id == environment.ID {
+				revisions = append(revisions, revision)
+				break
+			}
+		}
+	}
+
+	return revisions, nil
+}
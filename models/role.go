@@ -0,0 +1,117 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"gorm.io/gorm"
+)
+
+// lowers the access a role grants to an environment
+type EnvironmentRestriction struct {
+	ID            uuid.UUID   `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	EnvironmentID uuid.UUID   `gorm:"type:uuid;uniqueIndex:restriction_index" json:"environmentID"`
+	Environment   Environment `gorm:"foreignKey:EnvironmentID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Role          string      `gorm:"type:varchar(16);uniqueIndex:restriction_index;not null" json:"role"`
+	Access        string      `gorm:"type:varchar(16);not null" json:"access"`
+	CreatedAt     time.Time   `json:"createdAt"`
+	UpdatedAt     time.Time   `json:"updatedAt"`
+}
+
+func Authorize(db *gorm.DB, userID uuid.UUID, organizationID uuid.UUID, permission utils.Permission) (OrganizationMember, bool) {
+	var member OrganizationMember
+	if err := db.Where(
+		&OrganizationMember{OrganizationID: organizationID, UserID: userID},
+	).First(&member).Error; err != nil {
+		return member, false
+	}
+
+	return member, utils.RoleHasPermission(member.Role, permission)
+}
+
+func AuthorizeProject(db *gorm.DB, userID uuid.UUID, projectID uuid.UUID, permission utils.Permission) (OrganizationMember, bool) {
+	var member OrganizationMember
+	if err := db.Where(
+		"user_id=? AND organization_id IN (?)",
+		userID,
+		db.Session(&gorm.Session{NewDB: true}).Model(&Project{}).Select("organization_id").Where("id=?", projectID),
+	).First(&member).Error; err != nil {
+		return member, false
+	}
+
+	return member, utils.RoleHasPermission(member.Role, permission)
+}
+
+// restrictions can only lower the access the member's role grants
+func (member *OrganizationMember) EnvironmentAccess(db *gorm.DB, environmentIDs []uuid.UUID) (map[uuid.UUID]string, error) {
+	access := make(map[uuid.UUID]string, len(environmentIDs))
+	for _, id := range environmentIDs {
+		access[id] = utils.DefaultEnvironmentAccess(member.Role)
+	}
+
+	if !utils.RoleCanBeRestricted(member.Role) || len(environmentIDs) == 0 {
+		return access, nil
+	}
+
+	var restrictions []EnvironmentRestriction
+	if err := db.Where("environment_id IN ? AND role=?", environmentIDs, member.Role).Find(&restrictions).Error; err != nil {
+		return nil, err
+	}
+
+	for _, restriction := range restrictions {
+		access[restriction.EnvironmentID] = utils.MinAccess(access[restriction.EnvironmentID], restriction.Access)
+	}
+
+	return access, nil
+}
+
+func (member *OrganizationMember) CanAccessEnvironments(db *gorm.DB, environmentIDs []uuid.UUID, required string) bool {
+	access, err := member.EnvironmentAccess(db, environmentIDs)
+	if err != nil {
+		return false
+	}
+
+	for _, id := range environmentIDs {
+		if !utils.HasAccess(access[id], required) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (member *OrganizationMember) CanReadEnvironmentSecrets(db *gorm.DB, environment *Environment) bool {
+	lineage, err := GetEnvironmentLineage(db, environment)
+	if err != nil || len(lineage) == 0 {
+		return false
+	}
+
+	return member.CanAccessEnvironments(db, lineage, utils.AccessRead)
+}
+
+func (member *OrganizationMember) CanAccessAnyEnvironment(db *gorm.DB, environmentIDs []uuid.UUID, required string) bool {
+	access, err := member.EnvironmentAccess(db, environmentIDs)
+	if err != nil {
+		return false
+	}
+
+	for _, id := range environmentIDs {
+		if utils.HasAccess(access[id], required) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type ReqUpdateMemberRole struct {
+	ID   string `json:"id" validate:"required,uuid"`
+	Role string `json:"role" validate:"required,oneof=admin developer viewer"`
+}
+
+type ReqUpdateEnvironmentRestriction struct {
+	EnvironmentID string `json:"environmentID" validate:"required,uuid"`
+	Role          string `json:"role" validate:"required,oneof=developer viewer"`
+	Access        string `json:"access" validate:"required,oneof=write read none"`
+}
@@ -5,31 +5,52 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/mattcarlotta/nvi-api/utils"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 type Secret struct {
 	ID           uuid.UUID     `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	ProjectID    uuid.UUID     `gorm:"type:uuid;index" json:"projectID"`
+	Project      Project       `gorm:"foreignKey:ProjectID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
 	UserID       uuid.UUID     `gorm:"type:uuid" json:"userID"`
-	User         User          `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
 	Environments []Environment `gorm:"many2many:environment_secrets;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"environments"`
 	Key          string        `gorm:"type:varchar(255);not null" json:"key"`
 	Value        []byte        `gorm:"not null" json:"value"`
 	Nonce        []byte        `gorm:"not null" json:"nonce"`
+	SealVersion  int           `gorm:"not null;default:0" json:"-"`
+	Version      int           `gorm:"not null;default:1" json:"version"`
 	CreatedAt    time.Time     `json:"createdAt"`
 	UpdatedAt    time.Time     `json:"updatedAt"`
 }
 
+// the ID is generated here since the value is bound to it
 func (secret *Secret) BeforeCreate(tx *gorm.DB) (err error) {
-	if encText, nonce, err := utils.CreateEncryptedSecretValue(secret.Value); err == nil {
-		tx.Statement.SetColumn("Value", encText)
-		tx.Statement.SetColumn("Nonce", nonce)
+	project, err := GetSecretProject(tx.Session(&gorm.Session{NewDB: true}), secret.ProjectID)
+	if err != nil {
+		return err
 	}
+
+	if secret.ID == uuid.Nil {
+		secret.ID = uuid.New()
+	}
+
+	sealedValue, nonce, sealVersion, err := project.SealSecretValue(secret.ID, secret.Key, secret.Value)
+	if err != nil {
+		return err
+	}
+
+	tx.Statement.SetColumn("Value", sealedValue)
+	tx.Statement.SetColumn("Nonce", nonce)
+	tx.Statement.SetColumn("SealVersion", sealVersion)
+
 	return nil
 }
 
+func (secret *Secret) AfterCreate(tx *gorm.DB) (err error) {
+	return CreateSecretRevision(tx, secret)
+}
+
 func GetDupKeyinEnvs(secrets *[]Secret) string {
 	var envNames string
 	for _, secret := range *secrets {
@@ -64,27 +85,56 @@ func FindDupEnvNames(secrets *[]Secret, ids []uuid.UUID) string {
 	return envNames
 }
 
+// the value environment is only set when the environment overrides the secret's own value
 type SecretResult struct {
-	ID           uuid.UUID      `json:"id"`
-	UserID       uuid.UUID      `json:"userID"`
-	Environments datatypes.JSON `json:"environments"`
-	Key          string         `json:"key"`
-	Value        []byte         `json:"value"`
-	Nonce        []byte         `json:"-"`
-	CreatedAt    time.Time      `json:"createdAt"`
-	UpdatedAt    time.Time      `json:"updatedAt"`
+	ID                    uuid.UUID      `json:"id"`
+	ProjectID             uuid.UUID      `json:"projectID"`
+	UserID                uuid.UUID      `json:"userID"`
+	Environments          datatypes.JSON `json:"environments"`
+	Key                   string         `json:"key"`
+	Version               int            `json:"version"`
+	Value                 []byte         `json:"value"`
+	Nonce                 []byte         `json:"-"`
+	SealVersion           int            `json:"-"`
+	ValueEnvironmentID    *uuid.UUID     `json:"-"`
+	ResolvedValue         *string        `gorm:"-" json:"resolvedValue,omitempty"`
+	SourceEnvironmentID   uuid.UUID      `json:"sourceEnvironmentID"`
+	SourceEnvironmentName string         `json:"sourceEnvironmentName"`
+	CreatedAt             time.Time      `json:"createdAt"`
+	UpdatedAt             time.Time      `json:"updatedAt"`
 }
 
 type ReqCreateSecret struct {
 	ProjectID      string   `json:"projectID" validate:"required,uuid"`
 	EnvironmentIDs []string `json:"environmentIDs" validate:"uuidarray"`
-	Key            string   `json:"key" validate:"required,gte=2,lte=255"`
+	Key            string   `json:"key" validate:"required,secretkey,gte=2,lte=255"`
 	Value          string   `json:"value" validate:"required,lte=5000"`
 }
 
 type ReqUpdateSecret struct {
 	ID             string   `json:"id" validate:"required,uuid"`
 	EnvironmentIDs []string `json:"environmentIDs" validate:"uuidarray"`
-	Key            string   `json:"key" validate:"required,gte=2,lte=255"`
+	Key            string   `json:"key" validate:"required,secretkey,gte=2,lte=255"`
 	Value          string   `json:"value" validate:"required,lte=5000"`
 }
+
+// an empty value makes the environment serve the secret's own value again
+type ReqUpdateSecretEnvValue struct {
+	ID            string `json:"id" validate:"required,uuid"`
+	EnvironmentID string `json:"environmentID" validate:"required,uuid"`
+	Value         string `json:"value" validate:"lte=5000"`
+}
+
+type ReqImportSecrets struct {
+	ProjectID      string   `json:"projectID" validate:"required,uuid"`
+	EnvironmentIDs []string `json:"environmentIDs" validate:"uuidarray"`
+	Format         string   `json:"format" validate:"required,oneof=dotenv json yaml"`
+	Conflict       string   `json:"conflict" validate:"required,oneof=skip overwrite fail"`
+	Content        string   `json:"content" validate:"required,lte=500000"`
+}
+
+type ImportedSecret struct {
+	Key          string `json:"key"`
+	Action       string `json:"action"`
+	Environments string `json:"environments"`
+}
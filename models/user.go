@@ -14,8 +14,8 @@ type User struct {
 	Name      string    `gorm:"type:varchar(64);not null" json:"name"`
 	Email     string    `gorm:"type:varchar(255);uniqueIndex;not null" json:"email"`
 	Password  []byte    `gorm:"not null" json:"-"`
-	APIKey    string    `gorm:"not null" json:"apiKey"`
 	Verified  bool      `gorm:"default:false" json:"-"`
+	PublicKey []byte    `json:"publicKey"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
@@ -44,12 +44,13 @@ func (user *User) BeforeCreate(tx *gorm.DB) (err error) {
 		tx.Statement.SetColumn("Password", pw)
 	}
 
-	apiKEY := utils.CreateBase64EncodedUUID()
-	tx.Statement.SetColumn("APIKey", apiKEY)
-
 	return nil
 }
 
+func (user *User) AfterCreate(tx *gorm.DB) (err error) {
+	return CreatePersonalOrganization(tx, user)
+}
+
 type ReqRegisterUser struct {
 	Name     string `json:"name" validate:"required,gte=2,lte=64"`
 	Email    string `json:"email" validate:"required,email,lte=255"`
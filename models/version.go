@@ -0,0 +1,39 @@
+package models
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var ErrStaleVersion = errors.New("the resource has been changed or removed since its version was read")
+
+// fails when the resource has moved past the version it was read at
+func UpdateVersioned(db *gorm.DB, model interface{}, version *int, columns map[string]interface{}) error {
+	columns["version"] = gorm.Expr("version + 1")
+
+	result := db.Model(model).Where("version=?", *version).Updates(columns)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrStaleVersion
+	}
+
+	*version++
+	return nil
+}
+
+func DeleteVersioned(db *gorm.DB, model interface{}, version int) error {
+	result := db.Where("version=?", version).Delete(model)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrStaleVersion
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mattcarlotta/nvi-api/controllers"
+	"github.com/mattcarlotta/nvi-api/middlewares"
+)
+
+func APIKeyRoutes(app *fiber.App) {
+	apiKey := app.Group("/")
+	apiKey.Get("/apikeys", middlewares.RequiresCookieSession, controllers.GetAllAPIKeys)
+	apiKey.Post("/create/apikey", middlewares.RequiresCookieSession, controllers.CreateAPIKey)
+	apiKey.Delete("/revoke/apikey/:id", middlewares.RequiresCookieSession, controllers.RevokeAPIKey)
+}
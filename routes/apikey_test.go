@@ -0,0 +1,276 @@
+package routes
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mattcarlotta/nvi-api/models"
+	"github.com/mattcarlotta/nvi-api/test"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAllAPIKeysSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("get_all_api_keys_success@example.com", true)
+	testutils.CreateAPIKey(models.APIKey{UserID: u.ID, Name: "ci"})
+
+	test := &testutils.TestResponse{
+		Route:        "/apikeys",
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+}
+
+func TestCreateAPIKeyInvalidBody(t *testing.T) {
+	u, token, _ := testutils.CreateUser("create_api_key_invalid_body@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/create/apikey",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusBadRequest,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqCreateAPIKey{
+		Name:   "ci",
+		Access: "admin",
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.CreateAPIKeyInvalidBody])
+}
+
+func TestCreateAPIKeyExpired(t *testing.T) {
+	u, token, _ := testutils.CreateUser("create_api_key_expired@example.com", true)
+	expiresAt := time.Now().Add(-time.Hour)
+
+	test := &testutils.TestResponse{
+		Route:        "/create/apikey",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusBadRequest,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqCreateAPIKey{
+		Name:      "ci",
+		Access:    utils.AccessRead,
+		ExpiresAt: &expiresAt,
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.CreateAPIKeyInvalidBody])
+}
+
+func TestCreateAPIKeyNameTaken(t *testing.T) {
+	u, token, _ := testutils.CreateUser("create_api_key_name_taken@example.com", true)
+	testutils.CreateAPIKey(models.APIKey{UserID: u.ID, Name: "ci"})
+
+	test := &testutils.TestResponse{
+		Route:        "/create/apikey",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusConflict,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqCreateAPIKey{
+		Name:   "ci",
+		Access: utils.AccessRead,
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.CreateAPIKeyNameTaken])
+}
+
+func TestCreateAPIKeyNonExistentProject(t *testing.T) {
+	u, token, _ := testutils.CreateUser("create_api_key_non_existent_project@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/create/apikey",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusNotFound,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqCreateAPIKey{
+		Name:       "ci",
+		ProjectIDs: []string{uuid.NewString()},
+		Access:     utils.AccessRead,
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.CreateAPIKeyNonExistentProject])
+}
+
+func TestCreateAPIKeyNonExistentEnv(t *testing.T) {
+	u, token, _ := testutils.CreateUser("create_api_key_non_existent_env@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/create/apikey",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusNotFound,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqCreateAPIKey{
+		Name:           "ci",
+		EnvironmentIDs: []string{uuid.NewString()},
+		Access:         utils.AccessRead,
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.CreateAPIKeyNonExistentEnv])
+}
+
+func TestCreateAPIKeySuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("create_api_key_success@example.com", true)
+	p := testutils.CreateProject("create_api_key_success", token)
+	e := testutils.CreateEnvironment("production", p.ID, token)
+	expiresAt := time.Now().Add(time.Hour * 24)
+
+	test := &testutils.TestResponse{
+		Route:        "/create/apikey",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusCreated,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqCreateAPIKey{
+		Name:           "ci",
+		ProjectIDs:     []string{p.ID.String()},
+		EnvironmentIDs: []string{e.ID.String()},
+		Access:         utils.AccessRead,
+		ExpiresAt:      &expiresAt,
+	})
+
+	res := sendAppRequest(req)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+}
+
+func TestRevokeAPIKeyInvalidID(t *testing.T) {
+	u, token, _ := testutils.CreateUser("revoke_api_key_invalid_id@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/revoke/apikey/not_a_uuid",
+		Method:       fiber.MethodDelete,
+		ExpectedCode: fiber.StatusBadRequest,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.RevokeAPIKeyInvalidID])
+}
+
+func TestRevokeAPIKeyNonExistentID(t *testing.T) {
+	u, token, _ := testutils.CreateUser("revoke_api_key_non_existent_id@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/revoke/apikey/%s", uuid.New()),
+		Method:       fiber.MethodDelete,
+		ExpectedCode: fiber.StatusNotFound,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.RevokeAPIKeyNonExistentID])
+}
+
+func TestRevokeAPIKeySuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("revoke_api_key_success@example.com", true)
+	k := testutils.CreateAPIKey(models.APIKey{UserID: u.ID, Name: "ci"})
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/revoke/apikey/%s", k.ID),
+		Method:       fiber.MethodDelete,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+}
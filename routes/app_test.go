@@ -1,18 +1,36 @@
 package routes
 
 import (
+	"bufio"
+	"encoding/json"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/mattcarlotta/nvi-api/database"
+	"github.com/mattcarlotta/nvi-api/events"
+	"github.com/mattcarlotta/nvi-api/migrations"
 	"github.com/mattcarlotta/nvi-api/models"
+	"gorm.io/gorm"
 )
 
 var app *fiber.App
 
+// app.Test waits for the whole response, which never ends for an event stream, so streams are read from the app
+// while it's served over a listener instead
+var (
+	streamAddress string
+	serveStreams  sync.Once
+	streamClient  = &http.Client{Timeout: 10 * time.Second}
+)
+
 func sendAppRequest(req *http.Request) *http.Response {
 	res, err := app.Test(req, -1)
 	if err != nil {
@@ -22,21 +40,135 @@ func sendAppRequest(req *http.Request) *http.Response {
 	return res
 }
 
+// sends the request to the app served over a listener and returns the stream once its opening retry field has been
+// read, by which point the request is subscribed to its events
+func openAppStream(req *http.Request) (*http.Response, *bufio.Reader) {
+	serveStreams.Do(func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			log.Fatalf("Unable to listen for event streams: %s", err.Error())
+		}
+
+		streamAddress = listener.Addr().String()
+		go app.Listener(listener)
+	})
+
+	req.RequestURI = ""
+	req.URL.Scheme = "http"
+	req.URL.Host = streamAddress
+
+	res, err := streamClient.Do(req)
+	if err != nil {
+		log.Fatalf("Unable to open an event stream: %s", err.Error())
+	}
+
+	stream := bufio.NewReader(res.Body)
+	if res.StatusCode == fiber.StatusOK {
+		if line, err := stream.ReadString('\n'); err != nil || !strings.HasPrefix(line, "retry:") {
+			log.Fatalf("Unable to read the event stream's retry field: %v", err)
+		}
+	}
+
+	return res, stream
+}
+
+// reads the stream until its next event, skipping any comments
+func readStreamEvent(stream *bufio.Reader) (string, events.Event) {
+	var eventType string
+	var event events.Event
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			log.Fatalf("Unable to read an event from the stream: %s", err.Error())
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if field, ok := strings.CutPrefix(line, "event: "); ok {
+			eventType = field
+		} else if field, ok := strings.CutPrefix(line, "data: "); ok {
+			if err := json.Unmarshal([]byte(field), &event); err != nil {
+				log.Fatalf("Unable to parse an event from the stream: %s", err.Error())
+			}
+		} else if len(line) == 0 && len(eventType) > 0 {
+			return eventType, event
+		}
+	}
+}
+
+// the app only receives events once it's listening for notifications, which happens in the background
+func waitForEvents(db *gorm.DB) {
+	projectID, environmentID := uuid.New(), uuid.New()
+	subscription := events.Subscribe(projectID, []uuid.UUID{environmentID})
+	defer subscription.Close()
+
+	for attempt := 0; attempt < 50; attempt++ {
+		if err := events.Publish(db, events.Event{
+			Type: events.SecretCreate, ProjectID: projectID, EnvironmentIDs: []uuid.UUID{environmentID},
+		}); err != nil {
+			log.Fatalf("Unable to publish an event: %s", err.Error())
+		}
+
+		select {
+		case <-subscription.Events():
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	log.Fatal("Unable to receive events from the database")
+}
+
 func TestMain(m *testing.M) {
 	db := database.CreateConnection()
 
 	if err := db.Migrator().DropTable(&models.User{}); err != nil {
 		log.Fatalf("Unable to drop user table: %s", err.Error())
 	}
+	if err := db.Migrator().DropTable(&models.Organization{}); err != nil {
+		log.Fatalf("Unable to drop organization table: %s", err.Error())
+	}
+	if err := db.Migrator().DropTable(&models.OrganizationMember{}); err != nil {
+		log.Fatalf("Unable to drop organization member table: %s", err.Error())
+	}
+	if err := db.Migrator().DropTable(&models.OrganizationInvite{}); err != nil {
+		log.Fatalf("Unable to drop organization invite table: %s", err.Error())
+	}
+	if err := db.Migrator().DropTable(&models.Project{}); err != nil {
+		log.Fatalf("Unable to drop project table: %s", err.Error())
+	}
 	if err := db.Migrator().DropTable(&models.Environment{}); err != nil {
 		log.Fatalf("Unable to drop environment table: %s", err.Error())
 	}
 	if err := db.Migrator().DropTable(&models.Secret{}); err != nil {
 		log.Fatalf("Unable to drop secret table: %s", err.Error())
 	}
+	if err := db.Migrator().DropTable(&models.EnvironmentSecret{}); err != nil {
+		log.Fatalf("Unable to drop environment secret table: %s", err.Error())
+	}
+	if err := db.Migrator().DropTable(&models.SecretRevision{}); err != nil {
+		log.Fatalf("Unable to drop secret revision table: %s", err.Error())
+	}
+	if err := db.Migrator().DropTable(&models.EnvironmentRestriction{}); err != nil {
+		log.Fatalf("Unable to drop environment restriction table: %s", err.Error())
+	}
+	if err := db.Migrator().DropTable(&models.APIKey{}); err != nil {
+		log.Fatalf("Unable to drop api key table: %s", err.Error())
+	}
+	if err := db.Migrator().DropTable(&models.AuditEvent{}); err != nil {
+		log.Fatalf("Unable to drop audit event table: %s", err.Error())
+	}
+	if err := db.Migrator().DropTable(&models.KeyRotationJob{}); err != nil {
+		log.Fatalf("Unable to drop key rotation job table: %s", err.Error())
+	}
+	if err := db.Migrator().DropTable(&models.ProjectKeyShare{}); err != nil {
+		log.Fatalf("Unable to drop project key share table: %s", err.Error())
+	}
+	if err := db.Migrator().DropTable(&migrations.SchemaMigration{}); err != nil {
+		log.Fatalf("Unable to drop schema migration table: %s", err.Error())
+	}
 
-	if err := db.AutoMigrate(&models.User{}, &models.Environment{}, &models.Secret{}); err != nil {
-		log.Fatalf("Unable to migrate models: %s", err.Error())
+	if _, err := migrations.Up(db); err != nil {
+		log.Fatalf("Unable to migrate the database: %s", err.Error())
 	}
 
 	app = fiber.New()
@@ -46,6 +178,13 @@ func TestMain(m *testing.M) {
 	EnvironmentRoutes(app)
 	SecretRoutes(app)
 	ProjectRoutes(app)
+	OrganizationRoutes(app)
+	APIKeyRoutes(app)
+	AuditRoutes(app)
+	EncryptionRoutes(app)
+
+	events.Start(db)
+	waitForEvents(db)
 
 	os.Exit(m.Run())
 }
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mattcarlotta/nvi-api/controllers"
+	"github.com/mattcarlotta/nvi-api/middlewares"
+)
+
+func AuditRoutes(app *fiber.App) {
+	audit := app.Group("/")
+	audit.Get("/audit/project/:id", middlewares.RequiresCookieSession, controllers.GetAuditEventsByProjectID)
+	audit.Get("/audit/project/:id/verify", middlewares.RequiresCookieSession, controllers.VerifyAuditChainByProjectID)
+}
@@ -4,11 +4,14 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/mattcarlotta/nvi-api/controllers"
 	"github.com/mattcarlotta/nvi-api/middlewares"
+	"github.com/mattcarlotta/nvi-api/utils"
 )
 
 func CLIRoutes(app *fiber.App) {
 	cli := app.Group("/")
-	cli.Get("/cli/secrets", middlewares.RequiresAPIKey, controllers.GetSecretsByAPIKey)
-	cli.Get("/cli/projects", middlewares.RequiresAPIKey, controllers.GetProjectsByAPIKey)
-	cli.Get("/cli/environments", middlewares.RequiresAPIKey, controllers.GetEnvironmentsByAPIKey)
+	cli.Get("/cli/secrets", middlewares.RequiresAPIKey(utils.AccessRead), controllers.GetSecretsByAPIKey)
+	cli.Get("/cli/diff", middlewares.RequiresAPIKey(utils.AccessRead), controllers.DiffSecretsByAPIKey)
+	cli.Get("/cli/events", middlewares.RequiresAPIKey(utils.AccessRead), controllers.StreamEventsByAPIKey)
+	cli.Get("/cli/projects", middlewares.RequiresAPIKey(utils.AccessRead), controllers.GetProjectsByAPIKey)
+	cli.Get("/cli/environments", middlewares.RequiresAPIKey(utils.AccessRead), controllers.GetEnvironmentsByAPIKey)
 }
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mattcarlotta/nvi-api/controllers"
+	"github.com/mattcarlotta/nvi-api/middlewares"
+)
+
+func EncryptionRoutes(app *fiber.App) {
+	encryption := app.Group("/")
+	encryption.Get("/encryption/rotation", middlewares.RequiresCookieSession, controllers.GetMasterKeyRotationStatus)
+}
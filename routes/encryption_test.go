@@ -0,0 +1,310 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mattcarlotta/nvi-api/database"
+	"github.com/mattcarlotta/nvi-api/jobs"
+	"github.com/mattcarlotta/nvi-api/models"
+	"github.com/mattcarlotta/nvi-api/test"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMasterKeyRotationStatusSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("get_master_key_rotation_status@example.com", true)
+	testutils.CreateProject("get_master_key_rotation_status", token)
+
+	test := &testutils.TestResponse{
+		Route:        "/encryption/rotation",
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	var resBody map[string]interface{}
+	_ = json.NewDecoder(res.Body).Decode(&resBody)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, float64(0), resBody["remaining"])
+	assert.NotContains(t, resBody, "activeKeyID")
+	assert.NotContains(t, resBody, "error")
+}
+
+func TestGetKeyRotationStatusSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("get_key_rotation_status@example.com", true)
+	testutils.CreateProject("get_key_rotation_status", token)
+
+	status, err := models.GetKeyRotationStatus(database.GetConnection(), utils.ActiveKeyProvider.KeyID())
+
+	defer testutils.DeleteUser(&u)
+
+	assert.Nil(t, err)
+	assert.Equal(t, utils.ActiveKeyProvider.KeyID(), status.ActiveKeyID)
+	assert.Equal(t, int64(0), status.Remaining)
+}
+
+func TestRotateMasterKeySuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("rotate_master_key_success@example.com", true)
+
+	// the project's data key is wrapped by a master key that's then retired
+	activeProvider := utils.ActiveKeyProvider
+	retiredProvider, _ := utils.NewAESKeyProvider("rotate_master_key_retired", []byte("abcdefghijklmnopqrstuvwxyz012345"))
+	utils.SetActiveKeyProvider(retiredProvider)
+	p, _, s := testutils.CreateProjectAndEnvironmentAndSecret("rotate_master_key_project", "rotate_master_key_env", "ROTATED_KEY", "rotated_value", token)
+	utils.SetActiveKeyProvider(activeProvider)
+
+	err := jobs.RotateMasterKey(database.GetConnection())
+
+	// the retired key is no longer needed once the data key has been re-wrapped
+	delete(utils.KeyProviders, retiredProvider.KeyID())
+
+	var project models.Project
+	database.GetConnection().First(&project, "id=?", p.ID)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/secret/%s", s.ID.String()),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Nil(t, err)
+	assert.Equal(t, activeProvider.KeyID(), project.MasterKeyID)
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+}
+
+func TestKeyProviderFake(t *testing.T) {
+	u, token, _ := testutils.CreateUser("key_provider_fake@example.com", true)
+
+	activeProvider := utils.ActiveKeyProvider
+	fakeProvider := &testutils.FakeKeyProvider{ID: "key_provider_fake"}
+	utils.SetActiveKeyProvider(fakeProvider)
+	p, _, s := testutils.CreateProjectAndEnvironmentAndSecret("key_provider_fake_project", "key_provider_fake_env", "FAKE_KEY", "fake_value", token)
+	utils.SetActiveKeyProvider(activeProvider)
+
+	var project models.Project
+	database.GetConnection().First(&project, "id=?", p.ID)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/secret/%s", s.ID.String()),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, fakeProvider.KeyID(), project.MasterKeyID)
+	assert.Equal(t, 1, fakeProvider.Wraps)
+	assert.Greater(t, fakeProvider.Unwraps, 0)
+}
+
+func TestKeyProviderTransit(t *testing.T) {
+	backingProvider := &testutils.FakeKeyProvider{ID: "transit_backing_key"}
+	server := testutils.CreateTransitServer("transit_token", backingProvider)
+	defer server.Close()
+
+	provider, err := utils.NewTransitKeyProvider("transit_key", server.URL, "nvi", "transit_token")
+	assert.Nil(t, err)
+
+	dataKey := []byte("abcdefghijklmnopqrstuvwxyz012345")
+	wrappedKey, err := provider.WrapKey(dataKey)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(wrappedKey), "vault:v1:"))
+
+	unwrappedKey, err := provider.UnwrapKey(wrappedKey)
+	assert.Nil(t, err)
+	assert.Equal(t, dataKey, unwrappedKey)
+
+	invalidProvider, _ := utils.NewTransitKeyProvider("transit_key", server.URL, "nvi", "invalid_token")
+	_, err = invalidProvider.UnwrapKey(wrappedKey)
+	assert.NotNil(t, err)
+}
+
+func TestKeyProviderPassphrase(t *testing.T) {
+	provider, err := utils.NewPassphraseKeyProvider("passphrase_key", "correct horse battery staple", "0123456789abcdef")
+	assert.Nil(t, err)
+
+	dataKey := []byte("abcdefghijklmnopqrstuvwxyz012345")
+	wrappedKey, err := provider.WrapKey(dataKey)
+	assert.Nil(t, err)
+
+	// the same passphrase and salt always derive the same master key
+	sameProvider, _ := utils.NewPassphraseKeyProvider("passphrase_key", "correct horse battery staple", "0123456789abcdef")
+	unwrappedKey, err := sameProvider.UnwrapKey(wrappedKey)
+	assert.Nil(t, err)
+	assert.Equal(t, dataKey, unwrappedKey)
+
+	otherProvider, _ := utils.NewPassphraseKeyProvider("passphrase_key", "incorrect passphrase", "0123456789abcdef")
+	_, err = otherProvider.UnwrapKey(wrappedKey)
+	assert.NotNil(t, err)
+
+	_, err = utils.NewPassphraseKeyProvider("passphrase_key", "correct horse battery staple", "short")
+	assert.NotNil(t, err)
+}
+
+func TestUnboundSecretValueRejected(t *testing.T) {
+	u, token, _ := testutils.CreateUser("unbound_secret_value_rejected@example.com", true)
+	p, _, s := testutils.CreateProjectAndEnvironmentAndSecret("unbound_secret_value_project", "unbound_secret_value_env", "UNBOUND_KEY", "bound_value", token)
+
+	// the value is sealed the way it was before values were bound to their secret
+	db := database.GetConnection()
+	project, _ := models.GetSecretProject(db, p.ID)
+	dataKey, _ := project.UnwrapDataKey()
+	value, nonce, _ := utils.CreateEncryptedSecretValue(dataKey, []byte("unbound_value"), nil)
+	db.Model(&models.Secret{}).Where("id=?", s.ID).UpdateColumns(map[string]interface{}{
+		"value": value, "nonce": nonce, "seal_version": 0,
+	})
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/secret/%s", s.ID.String()),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusInternalServerError,
+	}
+
+	res := sendAppRequest(testutils.CreateAuthHTTPRequest(test, &token))
+
+	var resealErr error
+	for resealed := int64(1); resealed > 0 && resealErr == nil; {
+		resealed, resealErr = models.ResealSecretValues(db, 100)
+	}
+
+	resealed := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/secret/%s", s.ID.String()),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	resealedRes := sendAppRequest(testutils.CreateAuthHTTPRequest(resealed, &token))
+
+	var resBody secretResponse
+	_ = json.NewDecoder(resealedRes.Body).Decode(&resBody)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+		resealedRes.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Nil(t, resealErr)
+	assert.Equal(t, resealed.ExpectedCode, resealedRes.StatusCode)
+	assert.Equal(t, "unbound_value", resBody.Value)
+}
+
+func TestCreateLegacyProjectDataKeys(t *testing.T) {
+	u, token, _ := testutils.CreateUser("create_legacy_project_data_keys@example.com", true)
+	p, _, s := testutils.CreateProjectAndEnvironmentAndSecret("legacy_data_key_project", "legacy_data_key_env", "LEGACY_KEY", "bound_value", token)
+
+	// the project is left the way it was before projects had a data key of their own, with its values sealed
+	// directly with the ENCRYPTION_KEY
+	db := database.GetConnection()
+	masterKey, _ := utils.NewAESKeyProvider("legacy", []byte(os.Getenv("ENCRYPTION_KEY")))
+	sealed, _ := masterKey.WrapKey([]byte("legacy_value"))
+	db.Model(&models.Secret{}).Where("id=?", s.ID).UpdateColumns(map[string]interface{}{
+		"value": sealed[12:], "nonce": sealed[:12], "seal_version": 0,
+	})
+	db.Model(&models.SecretRevision{}).Where("secret_id=?", s.ID).UpdateColumns(map[string]interface{}{
+		"value": sealed[12:], "nonce": sealed[:12], "seal_version": 0,
+	})
+	db.Model(&models.Project{}).Where("id=?", p.ID).UpdateColumns(map[string]interface{}{
+		"wrapped_data_key": nil, "master_key_id": nil,
+	})
+
+	var migrateErr error
+	for created := int64(1); created > 0 && migrateErr == nil; {
+		created, migrateErr = models.CreateLegacyProjectDataKeys(db, 100)
+	}
+	for resealed := int64(1); resealed > 0 && migrateErr == nil; {
+		resealed, migrateErr = models.ResealSecretValues(db, 100)
+	}
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/secret/%s", s.ID.String()),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	res := sendAppRequest(testutils.CreateAuthHTTPRequest(test, &token))
+
+	var resBody secretResponse
+	_ = json.NewDecoder(res.Body).Decode(&resBody)
+
+	project, _ := models.GetSecretProject(db, p.ID)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Nil(t, migrateErr)
+	assert.NotEmpty(t, project.WrappedDataKey)
+	assert.Equal(t, utils.ActiveKeyProvider.KeyID(), project.MasterKeyID)
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, "legacy_value", resBody.Value)
+}
+
+func TestRetiredKeyProvidersMissingProvider(t *testing.T) {
+	_, err := utils.NewRetiredKeyProviders(`[{"id": "retired_key", "key": "abcdefghijklmnopqrstuvwxyz012345"}]`)
+
+	assert.EqualError(t, err, "the 'retired_key' retired master key must specify its provider")
+}
+
+func TestRetiredKeyProvidersSharedID(t *testing.T) {
+	providers, err := utils.NewRetiredKeyProviders(`[
+		{"provider": "env", "id": "default", "key": "abcdefghijklmnopqrstuvwxyz012345"},
+		{"provider": "passphrase", "id": "default", "passphrase": "correct horse battery staple", "salt": "0123456789abcdef"}
+	]`)
+
+	assert.Nil(t, err)
+	if assert.Len(t, providers, 2) {
+		assert.Equal(t, "env:default", providers[0].KeyID())
+		assert.Equal(t, "passphrase:default", providers[1].KeyID())
+	}
+}
+
+func TestUnwrapDataKeyUnqualifiedKeyID(t *testing.T) {
+	provider, _ := utils.NewKeyProvider(utils.KeyProviderConfig{
+		Provider: "env", ID: "unqualified_key", Key: "abcdefghijklmnopqrstuvwxyz012345",
+	})
+	utils.KeyProviders[provider.KeyID()] = provider
+	defer delete(utils.KeyProviders, provider.KeyID())
+
+	dataKey := []byte("0123456789abcdefghijklmnopqrstuv")
+	wrappedKey, _ := provider.WrapKey(dataKey)
+
+	// data keys wrapped before key IDs were qualified by their provider only stored the ID itself
+	unwrappedKey, err := utils.UnwrapDataKey("unqualified_key", wrappedKey)
+
+	assert.Nil(t, err)
+	assert.Equal(t, dataKey, unwrappedKey)
+}
@@ -12,7 +12,13 @@ func EnvironmentRoutes(app *fiber.App) {
 	environment.Get("/environments/project/:name", middlewares.RequiresCookieSession, controllers.GetAllEnvironmentByProjectName)
 	environment.Get("/environment/name", middlewares.RequiresCookieSession, controllers.GetEnvironmentByNameAndProjectID)
 	environment.Get("/environments/search", middlewares.RequiresCookieSession, controllers.SearchForEnvironmentsByNameAndProjectID)
+	environment.Get("/environment/restrictions/:id", middlewares.RequiresCookieSession, controllers.GetEnvironmentRestrictions)
+	environment.Get("/environment/events/:id", middlewares.RequiresCookieSession, controllers.StreamEnvironmentEvents)
 	environment.Post("/create/environment", middlewares.RequiresCookieSession, controllers.CreateEnvironment)
+	environment.Post("/clone/environment", middlewares.RequiresCookieSession, controllers.CloneEnvironment)
+	environment.Post("/promote/environment", middlewares.RequiresCookieSession, controllers.PromoteEnvironment)
 	environment.Delete("/delete/environment/:id", middlewares.RequiresCookieSession, controllers.DeleteEnvironment)
 	environment.Put("/update/environment", middlewares.RequiresCookieSession, controllers.UpdateEnvironment)
+	environment.Put("/update/environment/parent", middlewares.RequiresCookieSession, controllers.UpdateEnvironmentParent)
+	environment.Put("/update/environment/restriction", middlewares.RequiresCookieSession, controllers.UpdateEnvironmentRestriction)
 }
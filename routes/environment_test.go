@@ -1,11 +1,13 @@
 package routes
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/mattcarlotta/nvi-api/events"
 	"github.com/mattcarlotta/nvi-api/models"
 	"github.com/mattcarlotta/nvi-api/test"
 	"github.com/mattcarlotta/nvi-api/utils"
@@ -658,6 +660,39 @@ func TestUpdateEnvironmentNameTaken(t *testing.T) {
 	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.UpdateEnvironmentNameTaken])
 }
 
+func TestUpdateEnvironmentPreconditionFailed(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_env_precondition_failed@example.com", true)
+	p := testutils.CreateProject("update_env_precondition_failed", token)
+	e := testutils.CreateEnvironment("update_env_precondition_failed", p.ID, token)
+
+	env := &models.ReqUpdateEnv{
+		ID:          e.ID.String(),
+		ProjectID:   p.ID.String(),
+		UpdatedName: "updated_stale_env_name",
+	}
+
+	test := &testutils.TestResponse{
+		Route:        "/update/environment",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusPreconditionFailed,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, env)
+	req.Header.Set(fiber.HeaderIfMatch, utils.ETag(e.Version+1))
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, utils.JSONError(utils.PreconditionFailed), resBody)
+}
+
 func TestUpdateEnvironmentSuccess(t *testing.T) {
 	u, token, _ := testutils.CreateUser("update_env_success@example.com", true)
 	p := testutils.CreateProject("update_env_success", token)
@@ -687,3 +722,784 @@ func TestUpdateEnvironmentSuccess(t *testing.T) {
 
 	assert.Equal(t, test.ExpectedCode, res.StatusCode)
 }
+
+func TestGetEnvironmentRestrictionsInvalidID(t *testing.T) {
+	u, token, _ := testutils.CreateUser("get_env_restrictions_invalid_id@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/environment/restrictions/not_a_uuid",
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusBadRequest,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.GetEnvironmentRestrictionsInvalidID])
+}
+
+func TestGetEnvironmentRestrictionsNonExistentID(t *testing.T) {
+	u, token, _ := testutils.CreateUser("get_env_restrictions_non_existent_id@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/environment/restrictions/%s", uuid.New()),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusNotFound,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.GetEnvironmentRestrictionsNonExistentID])
+}
+
+func TestGetEnvironmentRestrictionsSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("get_env_restrictions_success@example.com", true)
+	p := testutils.CreateProject("get_env_restrictions_success", token)
+	e := testutils.CreateEnvironment("get_env_restrictions_success", p.ID, token)
+	testutils.CreateEnvironmentRestriction(e.ID, utils.RoleDeveloper, utils.AccessRead)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/environment/restrictions/%s", e.ID),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+}
+
+func TestUpdateEnvironmentRestrictionInvalidBody(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_env_restriction_invalid_body@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/environment/restriction",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusBadRequest,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateEnvironmentRestriction{
+		EnvironmentID: uuid.NewString(),
+		Role:          utils.RoleAdmin,
+		Access:        utils.AccessRead,
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.UpdateEnvironmentRestrictionInvalidBody])
+}
+
+func TestUpdateEnvironmentRestrictionNonExistentEnv(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_env_restriction_non_existent_env@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/environment/restriction",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusNotFound,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateEnvironmentRestriction{
+		EnvironmentID: uuid.NewString(),
+		Role:          utils.RoleDeveloper,
+		Access:        utils.AccessRead,
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.UpdateEnvironmentRestrictionNonExistentEnv])
+}
+
+func TestUpdateEnvironmentRestrictionPermissionDenied(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_env_restriction_permission_denied@example.com", true)
+	u2, token2, _ := testutils.CreateUser("update_env_restriction_permission_denied_2@example.com", true)
+	o := testutils.CreateOrganization("update_env_restriction_permission_denied", token2)
+	testutils.AddOrganizationMember(o.ID, u.ID, utils.RoleDeveloper)
+	p := testutils.CreateOrganizationProject("update_env_restriction_permission_denied", o.ID, token2)
+	e := testutils.CreateEnvironment("production", p.ID, token2)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/environment/restriction",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusForbidden,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateEnvironmentRestriction{
+		EnvironmentID: e.ID.String(),
+		Role:          utils.RoleViewer,
+		Access:        utils.AccessNone,
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		testutils.DeleteUser(&u2)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.PermissionDenied])
+}
+
+func TestUpdateEnvironmentRestrictionSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_env_restriction_success@example.com", true)
+	p := testutils.CreateProject("update_env_restriction_success", token)
+	e := testutils.CreateEnvironment("production", p.ID, token)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/environment/restriction",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateEnvironmentRestriction{
+		EnvironmentID: e.ID.String(),
+		Role:          utils.RoleDeveloper,
+		Access:        utils.AccessRead,
+	})
+
+	res := sendAppRequest(req)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+}
+
+func TestCreateEnvironmentNonExistentParent(t *testing.T) {
+	u, token, _ := testutils.CreateUser("create_env_non_existent_parent@example.com", true)
+	p := testutils.CreateProject("create_env_non_existent_parent", token)
+
+	test := &testutils.TestResponse{
+		Route:        "/create/environment",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusNotFound,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqCreateEnv{
+		Name:      "child",
+		ProjectID: p.ID.String(),
+		ParentID:  uuid.NewString(),
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.CreateEnvironmentNonExistentParent])
+}
+
+func TestUpdateEnvironmentParentCycle(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_env_parent_cycle@example.com", true)
+	p := testutils.CreateProject("update_env_parent_cycle", token)
+	base := testutils.CreateEnvironment("base", p.ID, token)
+	child := testutils.CreateEnvironment("child", p.ID, token)
+	testutils.SetEnvironmentParent(&child, base.ID)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/environment/parent",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusConflict,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateEnvParent{
+		ID:       base.ID.String(),
+		ParentID: child.ID.String(),
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.UpdateEnvironmentParentCycle])
+}
+
+func TestUpdateEnvironmentParentSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_env_parent_success@example.com", true)
+	p := testutils.CreateProject("update_env_parent_success", token)
+	base := testutils.CreateEnvironment("base", p.ID, token)
+	child := testutils.CreateEnvironment("child", p.ID, token)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/environment/parent",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateEnvParent{
+		ID:       child.ID.String(),
+		ParentID: base.ID.String(),
+	})
+
+	res := sendAppRequest(req)
+
+	var resBody models.Environment
+	_ = json.NewDecoder(res.Body).Decode(&resBody)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	if assert.NotNil(t, resBody.ParentID) {
+		assert.Equal(t, base.ID, *resBody.ParentID)
+	}
+}
+
+func TestCloneEnvironmentOverLimit(t *testing.T) {
+	u, token, _ := testutils.CreateUser("clone_env_over_limit@example.com", true)
+	p := testutils.CreateProject("clone_env_over_limit", token)
+	envs := [10]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	var e models.Environment
+	for _, i := range envs {
+		e = testutils.CreateEnvironment(fmt.Sprintf("env_limit_%d", i), p.ID, token)
+	}
+
+	test := &testutils.TestResponse{
+		Route:        "/clone/environment",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusForbidden,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqCloneEnv{ID: e.ID.String(), Name: "env_limit_11"})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.CloneEnvironmentOverLimit])
+}
+
+func TestCloneEnvironmentNameTaken(t *testing.T) {
+	u, token, _ := testutils.CreateUser("clone_env_name_taken@example.com", true)
+	p := testutils.CreateProject("clone_env_name_taken", token)
+	e := testutils.CreateEnvironment("staging", p.ID, token)
+	testutils.CreateEnvironment("qa", p.ID, token)
+
+	test := &testutils.TestResponse{
+		Route:        "/clone/environment",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusConflict,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqCloneEnv{ID: e.ID.String(), Name: "qa"})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.CloneEnvironmentNameTaken])
+}
+
+func TestCloneEnvironmentSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("clone_env_success@example.com", true)
+	p, e, s := testutils.CreateProjectAndEnvironmentAndSecret("clone_env_success", "staging", "API_URL", "https://staging.example.com", token)
+
+	test := &testutils.TestResponse{
+		Route:        "/clone/environment",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusCreated,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqCloneEnv{ID: e.ID.String(), Name: "qa"})
+
+	res := sendAppRequest(req)
+
+	var clone models.Environment
+	_ = json.NewDecoder(res.Body).Decode(&clone)
+
+	getSecrets := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/secrets/id/%s", clone.ID),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	getRes := sendAppRequest(testutils.CreateAuthHTTPRequest(getSecrets, &token))
+
+	var secrets []models.SecretResult
+	_ = json.NewDecoder(getRes.Body).Decode(&secrets)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+		getRes.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, "qa", clone.Name)
+	assert.Equal(t, p.ID, clone.ProjectID)
+	if assert.Len(t, secrets, 1) {
+		assert.Equal(t, s.ID, secrets[0].ID)
+	}
+}
+
+func TestPromoteEnvironmentOverLimit(t *testing.T) {
+	u, token, _ := testutils.CreateUser("promote_env_over_limit@example.com", true)
+	p := testutils.CreateProject("promote_env_over_limit", token)
+	envs := [10]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	var e models.Environment
+	for _, i := range envs {
+		e = testutils.CreateEnvironment(fmt.Sprintf("env_limit_%d", i), p.ID, token)
+	}
+
+	test := &testutils.TestResponse{
+		Route:        "/promote/environment",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusForbidden,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqPromoteEnv{
+		SourceID: e.ID.String(),
+		Target:   "env_limit_11",
+		Keys:     []string{"KEY"},
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.PromoteEnvironmentOverLimit])
+}
+
+type promotionPreview struct {
+	Target    string   `json:"target"`
+	Exists    bool     `json:"exists"`
+	Additions []string `json:"additions"`
+	Changes   []string `json:"changes"`
+	Removals  []string `json:"removals"`
+	Unchanged []string `json:"unchanged"`
+}
+
+func TestPromoteEnvironmentPreview(t *testing.T) {
+	u, token, _ := testutils.CreateUser("promote_env_preview@example.com", true)
+	p, staging, _ := testutils.CreateProjectAndEnvironmentAndSecret("promote_env_preview", "staging", "NEW_KEY", "abc", token)
+	testutils.CreateSecret(staging, "CHANGED_KEY", "staging_value", token)
+	testutils.CreateSecret(staging, "SAME_KEY", "same_value", token)
+	production, _ := testutils.CreateEnvironmentAndSecret("production", p.ID, "CHANGED_KEY", "production_value", token)
+	testutils.CreateSecret(production, "SAME_KEY", "same_value", token)
+	testutils.CreateSecret(production, "REMOVED_KEY", "abc", token)
+
+	test := &testutils.TestResponse{
+		Route:        "/promote/environment?preview=true",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqPromoteEnv{
+		SourceID: staging.ID.String(),
+		Target:   production.Name,
+		Keys:     []string{"NEW_KEY", "CHANGED_KEY", "SAME_KEY", "REMOVED_KEY"},
+	})
+
+	res := sendAppRequest(req)
+
+	var resBody promotionPreview
+	_ = json.NewDecoder(res.Body).Decode(&resBody)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, promotionPreview{
+		Target:    "production",
+		Exists:    true,
+		Additions: []string{"NEW_KEY"},
+		Changes:   []string{"CHANGED_KEY"},
+		Removals:  []string{"REMOVED_KEY"},
+		Unchanged: []string{"SAME_KEY"},
+	}, resBody)
+	assert.NotEmpty(t, res.Header.Get(fiber.HeaderETag))
+}
+
+func TestPromoteEnvironmentSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("promote_env_success@example.com", true)
+	p, staging, _ := testutils.CreateProjectAndEnvironmentAndSecret("promote_env_success", "staging", "NEW_KEY", "abc", token)
+	testutils.CreateSecret(staging, "CHANGED_KEY", "staging_value", token)
+	production, _ := testutils.CreateEnvironmentAndSecret("production", p.ID, "CHANGED_KEY", "production_value", token)
+	testutils.CreateSecret(production, "REMOVED_KEY", "abc", token)
+
+	promote := &models.ReqPromoteEnv{
+		SourceID: staging.ID.String(),
+		Target:   production.Name,
+		Keys:     []string{"NEW_KEY", "CHANGED_KEY", "REMOVED_KEY"},
+	}
+
+	test := &testutils.TestResponse{
+		Route:        "/promote/environment",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	res := sendAppRequest(testutils.CreateAuthHTTPRequest(test, &token, promote))
+
+	preview := &testutils.TestResponse{
+		Route:        "/promote/environment?preview=true",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	previewRes := sendAppRequest(testutils.CreateAuthHTTPRequest(preview, &token, promote))
+
+	var resBody promotionPreview
+	_ = json.NewDecoder(previewRes.Body).Decode(&resBody)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+		previewRes.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, preview.ExpectedCode, previewRes.StatusCode)
+	assert.Empty(t, resBody.Additions)
+	assert.Empty(t, resBody.Changes)
+	assert.Empty(t, resBody.Removals)
+	assert.Equal(t, []string{"NEW_KEY", "CHANGED_KEY"}, resBody.Unchanged)
+}
+
+func TestPromoteEnvironmentPreconditionFailed(t *testing.T) {
+	u, token, _ := testutils.CreateUser("promote_env_precondition_failed@example.com", true)
+	p, staging, _ := testutils.CreateProjectAndEnvironmentAndSecret("promote_env_precondition_failed", "staging", "CHANGED_KEY", "staging_value", token)
+	production, _ := testutils.CreateEnvironmentAndSecret("production", p.ID, "CHANGED_KEY", "production_value", token)
+
+	promote := &models.ReqPromoteEnv{
+		SourceID: staging.ID.String(),
+		Target:   production.Name,
+		Keys:     []string{"CHANGED_KEY", "ADDED_KEY"},
+	}
+
+	preview := &testutils.TestResponse{
+		Route:        "/promote/environment?preview=true",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	previewRes := sendAppRequest(testutils.CreateAuthHTTPRequest(preview, &token, promote))
+
+	// the target's secrets change after they were previewed
+	testutils.CreateSecret(production, "ADDED_KEY", "abc", token)
+
+	test := &testutils.TestResponse{
+		Route:        "/promote/environment",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusPreconditionFailed,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, promote)
+	req.Header.Set(fiber.HeaderIfMatch, previewRes.Header.Get(fiber.HeaderETag))
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	afterRes := sendAppRequest(testutils.CreateAuthHTTPRequest(preview, &token, promote))
+
+	var afterBody promotionPreview
+	_ = json.NewDecoder(afterRes.Body).Decode(&afterBody)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		previewRes.Body.Close()
+		res.Body.Close()
+		afterRes.Body.Close()
+	}()
+
+	assert.Equal(t, preview.ExpectedCode, previewRes.StatusCode)
+	assert.NotEmpty(t, previewRes.Header.Get(fiber.HeaderETag))
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, utils.JSONError(utils.PreconditionFailed), resBody)
+	assert.Equal(t, []string{"CHANGED_KEY"}, afterBody.Changes)
+	assert.Equal(t, []string{"ADDED_KEY"}, afterBody.Removals)
+	assert.NotEqual(t, previewRes.Header.Get(fiber.HeaderETag), afterRes.Header.Get(fiber.HeaderETag))
+}
+
+func TestStreamEnvironmentEventsInvalidID(t *testing.T) {
+	u, token, _ := testutils.CreateUser("stream_env_events_invalid_id@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/environment/events/not_a_uuid",
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusBadRequest,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.StreamEnvironmentEventsInvalidID])
+}
+
+func TestStreamEnvironmentEventsNonExistentID(t *testing.T) {
+	u, token, _ := testutils.CreateUser("stream_env_events_non_existent_id@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/environment/events/%s", uuid.NewString()),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusNotFound,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.StreamEnvironmentEventsNonExistentID])
+}
+
+func TestStreamEnvironmentEventsSecretCreate(t *testing.T) {
+	u, token, _ := testutils.CreateUser("stream_env_events_secret_create@example.com", true)
+	p := testutils.CreateProject("stream_env_events_secret_create", token)
+	e := testutils.CreateEnvironment("stream_env_events_secret_create", p.ID, token)
+
+	stream := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/environment/events/%s", e.ID.String()),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	streamRes, eventStream := openAppStream(testutils.CreateAuthHTTPRequest(stream, &token))
+
+	test := &testutils.TestResponse{
+		Route:        "/create/secret",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusCreated,
+	}
+
+	res := sendAppRequest(testutils.CreateAuthHTTPRequest(test, &token, &models.ReqCreateSecret{
+		ProjectID:      p.ID.String(),
+		EnvironmentIDs: []string{e.ID.String()},
+		Key:            "STREAMED_KEY",
+		Value:          "abc123",
+	}))
+
+	eventType, event := readStreamEvent(eventStream)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		streamRes.Body.Close()
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, stream.ExpectedCode, streamRes.StatusCode)
+	assert.Equal(t, "text/event-stream", streamRes.Header.Get(fiber.HeaderContentType))
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, events.SecretCreate, eventType)
+	assert.Equal(t, eventType, event.Type)
+	assert.Equal(t, p.ID, event.ProjectID)
+	assert.Equal(t, []uuid.UUID{e.ID}, event.EnvironmentIDs)
+	assert.Equal(t, 1, len(event.Secrets))
+	assert.Equal(t, "STREAMED_KEY", event.Secrets[0].Key)
+	assert.Equal(t, 1, event.Secrets[0].Version)
+}
+
+func TestStreamEnvironmentEventsSecretUpdate(t *testing.T) {
+	u, token, _ := testutils.CreateUser("stream_env_events_secret_update@example.com", true)
+	p, e, s := testutils.CreateProjectAndEnvironmentAndSecret("stream_env_events_secret_update", "stream_env_events_secret_update", "STREAMED_UPDATE_KEY", "abc123", token)
+
+	stream := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/environment/events/%s", e.ID.String()),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	streamRes, eventStream := openAppStream(testutils.CreateAuthHTTPRequest(stream, &token))
+
+	test := &testutils.TestResponse{
+		Route:        "/update/secret",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	res := sendAppRequest(testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateSecret{
+		ID:             s.ID.String(),
+		EnvironmentIDs: []string{e.ID.String()},
+		Key:            "STREAMED_UPDATE_KEY",
+		Value:          "def456",
+	}))
+
+	eventType, event := readStreamEvent(eventStream)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		streamRes.Body.Close()
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, stream.ExpectedCode, streamRes.StatusCode)
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, events.SecretUpdate, eventType)
+	assert.Equal(t, p.ID, event.ProjectID)
+	assert.Equal(t, []uuid.UUID{e.ID}, event.EnvironmentIDs)
+	assert.Equal(t, 1, len(event.Secrets))
+	assert.Equal(t, s.ID, event.Secrets[0].ID)
+	assert.Equal(t, s.Version+1, event.Secrets[0].Version)
+}
+
+func TestStreamEnvironmentEventsSecretDelete(t *testing.T) {
+	u, token, _ := testutils.CreateUser("stream_env_events_secret_delete@example.com", true)
+	p, e, s := testutils.CreateProjectAndEnvironmentAndSecret("stream_env_events_secret_delete", "stream_env_events_secret_delete", "STREAMED_DELETE_KEY", "abc123", token)
+
+	stream := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/environment/events/%s", e.ID.String()),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	streamRes, eventStream := openAppStream(testutils.CreateAuthHTTPRequest(stream, &token))
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/delete/secret/%s", s.ID.String()),
+		Method:       fiber.MethodDelete,
+		ExpectedCode: fiber.StatusCreated,
+	}
+
+	res := sendAppRequest(testutils.CreateAuthHTTPRequest(test, &token))
+
+	eventType, event := readStreamEvent(eventStream)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		streamRes.Body.Close()
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, stream.ExpectedCode, streamRes.StatusCode)
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, events.SecretDelete, eventType)
+	assert.Equal(t, p.ID, event.ProjectID)
+	assert.Equal(t, []uuid.UUID{e.ID}, event.EnvironmentIDs)
+	assert.Equal(t, 1, len(event.Secrets))
+	assert.Equal(t, s.ID, event.Secrets[0].ID)
+	assert.Equal(t, "STREAMED_DELETE_KEY", event.Secrets[0].Key)
+}
+
+func TestStreamEnvironmentEventsEnvironmentDelete(t *testing.T) {
+	u, token, _ := testutils.CreateUser("stream_env_events_env_delete@example.com", true)
+	p, e, s := testutils.CreateProjectAndEnvironmentAndSecret("stream_env_events_env_delete", "stream_env_events_env_delete", "STREAMED_ENV_KEY", "abc123", token)
+
+	stream := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/environment/events/%s", e.ID.String()),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	streamRes, eventStream := openAppStream(testutils.CreateAuthHTTPRequest(stream, &token))
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/delete/environment/%s", e.ID.String()),
+		Method:       fiber.MethodDelete,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	res := sendAppRequest(testutils.CreateAuthHTTPRequest(test, &token))
+
+	eventType, event := readStreamEvent(eventStream)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		streamRes.Body.Close()
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, stream.ExpectedCode, streamRes.StatusCode)
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, events.EnvironmentDelete, eventType)
+	assert.Equal(t, p.ID, event.ProjectID)
+	assert.Equal(t, []uuid.UUID{e.ID}, event.EnvironmentIDs)
+	assert.Equal(t, 1, len(event.Secrets))
+	assert.Equal(t, s.ID, event.Secrets[0].ID)
+}
@@ -0,0 +1,255 @@
+package routes
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mattcarlotta/nvi-api/models"
+	"github.com/mattcarlotta/nvi-api/test"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdatePublicKeyInvalidBody(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_public_key_invalid_body@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/publickey",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusBadRequest,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdatePublicKey{
+		PublicKey: base64.StdEncoding.EncodeToString([]byte("too_short")),
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.UpdatePublicKeyInvalidBody])
+}
+
+func TestUpdatePublicKeySuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_public_key_success@example.com", true)
+	privateKey, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	publicKey := base64.StdEncoding.EncodeToString(privateKey.PublicKey().Bytes())
+
+	test := &testutils.TestResponse{
+		Route:        "/update/publickey",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdatePublicKey{PublicKey: publicKey})
+
+	res := sendAppRequest(req)
+
+	var resBody models.ReqUpdatePublicKey
+	_ = json.NewDecoder(res.Body).Decode(&resBody)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, publicKey, resBody.PublicKey)
+}
+
+func TestGetProjectPublicKeysNonExistentProject(t *testing.T) {
+	u, token, _ := testutils.CreateUser("get_project_public_keys_non_existent@example.com", true)
+	otherUser, otherToken, _ := testutils.CreateUser("get_project_public_keys_non_existent_other@example.com", true)
+	p := testutils.CreateEndToEndProject("get_project_public_keys_non_existent", otherToken)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/project/publickeys/%s", p.ID),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusNotFound,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		testutils.DeleteUser(&otherUser)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.GetProjectPublicKeysNonExistentProject])
+}
+
+func TestGetProjectPublicKeysSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("get_project_public_keys_success@example.com", true)
+	privateKey := testutils.CreatePublicKey(u.ID)
+	p := testutils.CreateEndToEndProject("get_project_public_keys_success", token)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/project/publickeys/%s", p.ID),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	var resBody []models.MemberPublicKey
+	_ = json.NewDecoder(res.Body).Decode(&resBody)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	if assert.Len(t, resBody, 1) {
+		assert.Equal(t, u.ID, resBody[0].UserID)
+		assert.Equal(t, privateKey.PublicKey().Bytes(), resBody[0].PublicKey)
+		assert.False(t, resBody[0].HasKeyShare)
+	}
+}
+
+func TestGetProjectKeyShareNonExistentShare(t *testing.T) {
+	u, token, _ := testutils.CreateUser("get_project_key_share_non_existent@example.com", true)
+	p := testutils.CreateEndToEndProject("get_project_key_share_non_existent", token)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/project/keyshare/%s", p.ID),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusNotFound,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.GetKeyShareNonExistentShare])
+}
+
+func TestUpdateProjectKeyShareNotEndToEnd(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_project_key_share_not_e2e@example.com", true)
+	testutils.CreatePublicKey(u.ID)
+	p := testutils.CreateProject("update_project_key_share_not_e2e", token)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/project/keyshare",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusBadRequest,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateKeyShare{
+		ProjectID:    p.ID.String(),
+		UserID:       u.ID.String(),
+		EncryptedKey: base64.StdEncoding.EncodeToString([]byte("sealed_project_key")),
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.UpdateKeyShareNotEndToEnd])
+}
+
+func TestUpdateProjectKeyShareNonExistentMember(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_project_key_share_no_member@example.com", true)
+	p := testutils.CreateEndToEndProject("update_project_key_share_no_member", token)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/project/keyshare",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusNotFound,
+	}
+
+	// the user hasn't uploaded a public key, so nothing can be sealed for them
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateKeyShare{
+		ProjectID:    p.ID.String(),
+		UserID:       u.ID.String(),
+		EncryptedKey: base64.StdEncoding.EncodeToString([]byte("sealed_project_key")),
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.UpdateKeyShareNonExistentMember])
+}
+
+func TestUpdateProjectKeyShareSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_project_key_share_success@example.com", true)
+	testutils.CreatePublicKey(u.ID)
+	p := testutils.CreateEndToEndProject("update_project_key_share_success", token)
+	encryptedKey := []byte("sealed_project_key")
+
+	test := &testutils.TestResponse{
+		Route:        "/update/project/keyshare",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateKeyShare{
+		ProjectID:    p.ID.String(),
+		UserID:       u.ID.String(),
+		EncryptedKey: base64.StdEncoding.EncodeToString(encryptedKey),
+	})
+
+	res := sendAppRequest(req)
+
+	getKeyShare := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/project/keyshare/%s", p.ID),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	getRes := sendAppRequest(testutils.CreateAuthHTTPRequest(getKeyShare, &token))
+
+	var keyShare models.ProjectKeyShare
+	_ = json.NewDecoder(getRes.Body).Decode(&keyShare)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+		getRes.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, getKeyShare.ExpectedCode, getRes.StatusCode)
+	assert.Equal(t, encryptedKey, keyShare.EncryptedKey)
+	assert.Equal(t, u.ID, keyShare.SharedByUserID)
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mattcarlotta/nvi-api/controllers"
+	"github.com/mattcarlotta/nvi-api/middlewares"
+)
+
+func OrganizationRoutes(app *fiber.App) {
+	organization := app.Group("/")
+	organization.Get("/organization/id/:id", middlewares.RequiresCookieSession, controllers.GetOrganizationByID)
+	organization.Get("/organizations", middlewares.RequiresCookieSession, controllers.GetAllOrganizations)
+	organization.Post("/create/organization/:name", middlewares.RequiresCookieSession, controllers.CreateOrganization)
+	organization.Post("/invite/member", middlewares.RequiresCookieSession, controllers.InviteMember)
+	organization.Patch("/accept/invite", middlewares.RequiresCookieSession, controllers.AcceptInvite)
+	organization.Delete("/delete/organization/:id", middlewares.RequiresCookieSession, controllers.DeleteOrganization)
+	organization.Delete("/delete/member/:id", middlewares.RequiresCookieSession, controllers.RemoveMember)
+	organization.Put("/update/organization", middlewares.RequiresCookieSession, controllers.UpdateOrganization)
+	organization.Put("/update/member/role", middlewares.RequiresCookieSession, controllers.UpdateMemberRole)
+}
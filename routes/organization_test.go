@@ -0,0 +1,827 @@
+package routes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mattcarlotta/nvi-api/models"
+	"github.com/mattcarlotta/nvi-api/test"
+	"github.com/mattcarlotta/nvi-api/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAllOrganizationsSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("get_all_organizations@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/organizations",
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+}
+
+func TestGetOrganizationByIDInvalidID(t *testing.T) {
+	u, token, _ := testutils.CreateUser("get_organization_invalid_id@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/organization/id/not_a_uuid",
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusBadRequest,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.GetOrganizationInvalidID])
+}
+
+func TestGetOrganizationByIDNonMember(t *testing.T) {
+	u, token, _ := testutils.CreateUser("get_organization_non_member@example.com", true)
+	u2, token2, _ := testutils.CreateUser("get_organization_non_member_2@example.com", true)
+	o := testutils.CreateOrganization("get_organization_non_member", token2)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/organization/id/%s", o.ID),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusNotFound,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		testutils.DeleteUser(&u2)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.GetOrganizationNonExistentID])
+}
+
+func TestGetOrganizationByIDSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("get_organization_success@example.com", true)
+	o := testutils.CreateOrganization("get_organization_success", token)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/organization/id/%s", o.ID),
+		Method:       fiber.MethodGet,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+}
+
+func TestCreateOrganizationInvalidName(t *testing.T) {
+	u, token, _ := testutils.CreateUser("create_organization_invalid_name@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/create/organization/*",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusBadRequest,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.CreateOrganizationInvalidName])
+}
+
+func TestCreateOrganizationNameTaken(t *testing.T) {
+	u, token, _ := testutils.CreateUser("create_organization_name_taken@example.com", true)
+	o := testutils.CreateOrganization("create_organization_name_taken", token)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/create/organization/%s", o.Name),
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusConflict,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.CreateOrganizationNameTaken])
+}
+
+func TestCreateOrganizationSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("create_organization_success@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/create/organization/create_organization_success",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusCreated,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+}
+
+func TestUpdateOrganizationInvalidBody(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_organization_invalid_body@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/organization",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusBadRequest,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.UpdateOrganizationInvalidBody])
+}
+
+func TestUpdateOrganizationNonOwner(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_organization_non_owner@example.com", true)
+	u2, token2, _ := testutils.CreateUser("update_organization_non_owner_2@example.com", true)
+	o := testutils.CreateOrganization("update_organization_non_owner", token2)
+	testutils.AddOrganizationMember(o.ID, u.ID, utils.RoleDeveloper)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/organization",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusForbidden,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateOrganization{
+		ID:          o.ID.String(),
+		UpdatedName: "update_organization_non_owner_updated",
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		testutils.DeleteUser(&u2)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.PermissionDenied])
+}
+
+func TestUpdateOrganizationNameTaken(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_organization_name_taken@example.com", true)
+	o := testutils.CreateOrganization("update_organization_name_taken", token)
+	o2 := testutils.CreateOrganization("update_organization_name_taken_2", token)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/organization",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusConflict,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateOrganization{
+		ID:          o.ID.String(),
+		UpdatedName: o2.Name,
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.UpdateOrganizationNameTaken])
+}
+
+func TestUpdateOrganizationSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_organization_success@example.com", true)
+	o := testutils.CreateOrganization("update_organization_success", token)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/organization",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateOrganization{
+		ID:          o.ID.String(),
+		UpdatedName: "update_organization_success_updated",
+	})
+
+	res := sendAppRequest(req)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+}
+
+func TestDeleteOrganizationInvalidID(t *testing.T) {
+	u, token, _ := testutils.CreateUser("delete_organization_invalid_id@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/delete/organization/not_a_uuid",
+		Method:       fiber.MethodDelete,
+		ExpectedCode: fiber.StatusBadRequest,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.DeleteOrganizationInvalidID])
+}
+
+func TestDeleteOrganizationNonExistentID(t *testing.T) {
+	u, token, _ := testutils.CreateUser("delete_organization_non_existent_id@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/delete/organization/%s", uuid.NewString()),
+		Method:       fiber.MethodDelete,
+		ExpectedCode: fiber.StatusNotFound,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.DeleteOrganizationNonExistentID])
+}
+
+func TestDeleteOrganizationPersonal(t *testing.T) {
+	u, token, _ := testutils.CreateUser("delete_organization_personal@example.com", true)
+	o := testutils.GetPersonalOrganization(u.ID)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/delete/organization/%s", o.ID),
+		Method:       fiber.MethodDelete,
+		ExpectedCode: fiber.StatusForbidden,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.DeleteOrganizationPersonal])
+}
+
+func TestDeleteOrganizationSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("delete_organization_success@example.com", true)
+	o := testutils.CreateOrganization("delete_organization_success", token)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/delete/organization/%s", o.ID),
+		Method:       fiber.MethodDelete,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+}
+
+func TestInviteMemberInvalidBody(t *testing.T) {
+	u, token, _ := testutils.CreateUser("invite_member_invalid_body@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/invite/member",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusBadRequest,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.InviteMemberInvalidBody])
+}
+
+func TestInviteMemberNonExistentOrganization(t *testing.T) {
+	u, token, _ := testutils.CreateUser("invite_member_non_existent_org@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/invite/member",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusNotFound,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqInviteMember{
+		OrganizationID: uuid.NewString(),
+		Email:          "invitee@example.com",
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.InviteMemberNonExistentOrganization])
+}
+
+func TestInviteMemberAlreadyMember(t *testing.T) {
+	u, token, _ := testutils.CreateUser("invite_member_already_member@example.com", true)
+	u2, _, _ := testutils.CreateUser("invite_member_already_member_2@example.com", true)
+	o := testutils.CreateOrganization("invite_member_already_member", token)
+	testutils.AddOrganizationMember(o.ID, u2.ID, utils.RoleDeveloper)
+
+	test := &testutils.TestResponse{
+		Route:        "/invite/member",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusConflict,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqInviteMember{
+		OrganizationID: o.ID.String(),
+		Email:          u2.Email,
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		testutils.DeleteUser(&u2)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.InviteMemberAlreadyMember])
+}
+
+func TestInviteMemberSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("invite_member_success@example.com", true)
+	o := testutils.CreateOrganization("invite_member_success", token)
+
+	test := &testutils.TestResponse{
+		Route:        "/invite/member",
+		Method:       fiber.MethodPost,
+		ExpectedCode: fiber.StatusCreated,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqInviteMember{
+		OrganizationID: o.ID.String(),
+		Email:          "invite_member_success_invitee@example.com",
+	})
+
+	res := sendAppRequest(req)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+}
+
+func TestAcceptInviteInvalidToken(t *testing.T) {
+	u, token, _ := testutils.CreateUser("accept_invite_invalid_token@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/accept/invite?token=not_a_token",
+		Method:       fiber.MethodPatch,
+		ExpectedCode: fiber.StatusBadRequest,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.AcceptInviteInvalidToken])
+}
+
+func TestAcceptInviteWrongAccount(t *testing.T) {
+	u, token, _ := testutils.CreateUser("accept_invite_wrong_account@example.com", true)
+	u2, token2, _ := testutils.CreateUser("accept_invite_wrong_account_2@example.com", true)
+	o := testutils.CreateOrganization("accept_invite_wrong_account", token2)
+	testutils.CreateOrganizationInvite(o.ID, "accept_invite_wrong_account_3@example.com", token2)
+
+	inviteToken, _, err := utils.GenerateInviteToken("accept_invite_wrong_account_3@example.com", o.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/accept/invite?token=%s", inviteToken),
+		Method:       fiber.MethodPatch,
+		ExpectedCode: fiber.StatusNotFound,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		testutils.DeleteUser(&u2)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.AcceptInviteNonExistentInvite])
+}
+
+func TestAcceptInviteSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("accept_invite_success@example.com", true)
+	u2, token2, _ := testutils.CreateUser("accept_invite_success_2@example.com", true)
+	o := testutils.CreateOrganization("accept_invite_success", token2)
+	p := testutils.CreateOrganizationProject("accept_invite_success", o.ID, token2)
+	testutils.CreateOrganizationInvite(o.ID, u.Email, token2)
+
+	inviteToken, _, err := utils.GenerateInviteToken(u.Email, o.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/accept/invite?token=%s", inviteToken),
+		Method:       fiber.MethodPatch,
+		ExpectedCode: fiber.StatusCreated,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	// the new member can access the organization's projects
+	projectRes := sendAppRequest(testutils.CreateAuthHTTPRequest(&testutils.TestResponse{
+		Route:  fmt.Sprintf("/project/id/%s", p.ID),
+		Method: fiber.MethodGet,
+	}, &token))
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		testutils.DeleteUser(&u2)
+		res.Body.Close()
+		projectRes.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, fiber.StatusOK, projectRes.StatusCode)
+}
+
+func TestRemoveMemberInvalidID(t *testing.T) {
+	u, token, _ := testutils.CreateUser("remove_member_invalid_id@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/delete/member/not_a_uuid",
+		Method:       fiber.MethodDelete,
+		ExpectedCode: fiber.StatusBadRequest,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.RemoveMemberInvalidID])
+}
+
+func TestRemoveMemberNonExistentID(t *testing.T) {
+	u, token, _ := testutils.CreateUser("remove_member_non_existent_id@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/delete/member/%s", uuid.NewString()),
+		Method:       fiber.MethodDelete,
+		ExpectedCode: fiber.StatusNotFound,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.RemoveMemberNonExistentID])
+}
+
+func TestRemoveMemberOwner(t *testing.T) {
+	u, token, _ := testutils.CreateUser("remove_member_owner@example.com", true)
+	o := testutils.CreateOrganization("remove_member_owner", token)
+
+	member := testutils.GetOrganizationMember(o.ID, u.ID)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/delete/member/%s", member.ID),
+		Method:       fiber.MethodDelete,
+		ExpectedCode: fiber.StatusForbidden,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.RemoveMemberOwner])
+}
+
+func TestRemoveMemberSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("remove_member_success@example.com", true)
+	u2, _, _ := testutils.CreateUser("remove_member_success_2@example.com", true)
+	o := testutils.CreateOrganization("remove_member_success", token)
+	m := testutils.AddOrganizationMember(o.ID, u2.ID, utils.RoleDeveloper)
+
+	test := &testutils.TestResponse{
+		Route:        fmt.Sprintf("/delete/member/%s", m.ID),
+		Method:       fiber.MethodDelete,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token)
+
+	res := sendAppRequest(req)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		testutils.DeleteUser(&u2)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+}
+
+func TestUpdateMemberRoleInvalidBody(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_member_role_invalid_body@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/member/role",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusBadRequest,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateMemberRole{
+		ID:   uuid.NewString(),
+		Role: utils.RoleOwner,
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.UpdateMemberRoleInvalidBody])
+}
+
+func TestUpdateMemberRoleNonExistentID(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_member_role_non_existent_id@example.com", true)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/member/role",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusNotFound,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateMemberRole{
+		ID:   uuid.NewString(),
+		Role: utils.RoleViewer,
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.UpdateMemberRoleNonExistentID])
+}
+
+func TestUpdateMemberRolePermissionDenied(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_member_role_permission_denied@example.com", true)
+	u2, token2, _ := testutils.CreateUser("update_member_role_permission_denied_2@example.com", true)
+	o := testutils.CreateOrganization("update_member_role_permission_denied", token2)
+	m := testutils.AddOrganizationMember(o.ID, u.ID, utils.RoleDeveloper)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/member/role",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusForbidden,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateMemberRole{
+		ID:   m.ID.String(),
+		Role: utils.RoleAdmin,
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		testutils.DeleteUser(&u2)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.PermissionDenied])
+}
+
+func TestUpdateMemberRoleOwner(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_member_role_owner@example.com", true)
+	o := testutils.CreateOrganization("update_member_role_owner", token)
+	m := testutils.GetOrganizationMember(o.ID, u.ID)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/member/role",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusForbidden,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateMemberRole{
+		ID:   m.ID.String(),
+		Role: utils.RoleViewer,
+	})
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.UpdateMemberRoleOwner])
+}
+
+func TestUpdateMemberRoleSuccess(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_member_role_success@example.com", true)
+	u2, _, _ := testutils.CreateUser("update_member_role_success_2@example.com", true)
+	o := testutils.CreateOrganization("update_member_role_success", token)
+	m := testutils.AddOrganizationMember(o.ID, u2.ID, utils.RoleDeveloper)
+
+	test := &testutils.TestResponse{
+		Route:        "/update/member/role",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusOK,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, &models.ReqUpdateMemberRole{
+		ID:   m.ID.String(),
+		Role: utils.RoleViewer,
+	})
+
+	res := sendAppRequest(req)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		testutils.DeleteUser(&u2)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, utils.RoleViewer, testutils.GetOrganizationMember(o.ID, u2.ID).Role)
+}
@@ -15,4 +15,7 @@ func ProjectRoutes(app *fiber.App) {
 	project.Post("/create/project/:name", middlewares.RequiresCookieSession, controllers.CreateProject)
 	project.Delete("/delete/project/:id", middlewares.RequiresCookieSession, controllers.DeleteProject)
 	project.Put("/update/project", middlewares.RequiresCookieSession, controllers.UpdateProject)
+	project.Get("/project/publickeys/:id", middlewares.RequiresCookieSession, controllers.GetProjectPublicKeys)
+	project.Get("/project/keyshare/:id", middlewares.RequiresCookieSession, controllers.GetProjectKeyShare)
+	project.Put("/update/project/keyshare", middlewares.RequiresCookieSession, controllers.UpdateProjectKeyShare)
 }
@@ -504,6 +504,37 @@ func TestUpdateProjectNameTaken(t *testing.T) {
 	assert.Equal(t, resBody.Error, utils.ErrorCode[utils.UpdateProjectNameTaken])
 }
 
+func TestUpdateProjectPreconditionFailed(t *testing.T) {
+	u, token, _ := testutils.CreateUser("update_project_precondition_failed@example.com", true)
+	p := testutils.CreateProject("update_project_precondition_failed", token)
+
+	project := &models.ReqUpdateProject{
+		ID:          p.ID.String(),
+		UpdatedName: "updated_stale_project_name",
+	}
+
+	test := &testutils.TestResponse{
+		Route:        "/update/project",
+		Method:       fiber.MethodPut,
+		ExpectedCode: fiber.StatusPreconditionFailed,
+	}
+
+	req := testutils.CreateAuthHTTPRequest(test, &token, project)
+	req.Header.Set(fiber.HeaderIfMatch, utils.ETag(p.Version+1))
+
+	res := sendAppRequest(req)
+
+	resBody := testutils.ParseJSONBodyError(&res.Body)
+
+	defer func() {
+		testutils.DeleteUser(&u)
+		res.Body.Close()
+	}()
+
+	assert.Equal(t, test.ExpectedCode, res.StatusCode)
+	assert.Equal(t, utils.JSONError(utils.PreconditionFailed), resBody)
+}
+
 func TestUpdateProjectSuccess(t *testing.T) {
 	u, token, _ := testutils.CreateUser("update_project_success@example.com", true)
 	p := testutils.CreateProject("update_project_success", token)
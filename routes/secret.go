@@ -11,8 +11,14 @@ func SecretRoutes(app *fiber.App) {
 	secret.Get("/secrets/projectenvironment", middlewares.RequiresCookieSession, controllers.GetSecretsByProjectAndEnvironmentName)
 	secret.Get("/secret/:id", middlewares.RequiresCookieSession, controllers.GetSecretBySecretID)
 	secret.Get("/secrets/id/:id", middlewares.RequiresCookieSession, controllers.GetSecretsByEnvironmentID)
+	secret.Get("/secrets/diff", middlewares.RequiresCookieSession, controllers.DiffSecrets)
 	secret.Get("/secrets/search", middlewares.RequiresCookieSession, controllers.SearchForSecretsByEnvironmentIDAndSecretKey)
+	secret.Get("/secret/revisions/:id", middlewares.RequiresCookieSession, controllers.GetSecretRevisionsBySecretID)
+	secret.Get("/secret/revision/:id", middlewares.RequiresCookieSession, controllers.GetSecretRevisionByID)
 	secret.Post("/create/secret", middlewares.RequiresCookieSession, controllers.CreateSecret)
+	secret.Post("/import/secrets", middlewares.RequiresCookieSession, controllers.ImportSecrets)
 	secret.Delete("/delete/secret/:id", middlewares.RequiresCookieSession, controllers.DeleteSecret)
 	secret.Put("/update/secret/", middlewares.RequiresCookieSession, controllers.UpdateSecret)
+	secret.Put("/update/secret/environmentvalue", middlewares.RequiresCookieSession, controllers.UpdateSecretEnvironmentValue)
+	secret.Put("/restore/secret/revision/:id", middlewares.RequiresCookieSession, controllers.RestoreSecretRevision)
 }
@@ -17,7 +17,7 @@ func UserRoutes(app *fiber.App) {
 	user.Patch("/reset/password", controllers.SendResetPasswordEmail)
 	user.Patch("/update/password", controllers.UpdatePassword)
 	user.Patch("/update/name", middlewares.RequiresCookieSession, controllers.UpdateDisplayName)
-	user.Patch("/update/apikey", middlewares.RequiresCookieSession, controllers.UpdateAPIKey)
+	user.Put("/update/publickey", middlewares.RequiresCookieSession, controllers.UpdatePublicKey)
 	user.Get("/account", middlewares.RequiresCookieSession, controllers.GetAccountInfo)
 	user.Delete("/delete/account", middlewares.RequiresCookieSession, controllers.DeleteAccount)
 }
@@ -656,27 +656,6 @@ func TestUpdateDisplayNameSuccess(t *testing.T) {
 	assert.Equal(t, test.ExpectedCode, res.StatusCode)
 }
 
-func TestUpdateAPIKeySuccess(t *testing.T) {
-	u, token, _ := testutils.CreateUser("update_api_key_success@example.com", true)
-
-	test := &testutils.TestResponse{
-		Route:        "/update/apikey",
-		Method:       fiber.MethodPatch,
-		ExpectedCode: fiber.StatusCreated,
-	}
-
-	req := testutils.CreateAuthHTTPRequest(test, &token)
-
-	res := sendAppRequest(req)
-
-	defer func() {
-		testutils.DeleteUser(&u)
-		res.Body.Close()
-	}()
-
-	assert.Equal(t, test.ExpectedCode, res.StatusCode)
-}
-
 func TestGetAccountInfoSuccess(t *testing.T) {
 	u, token, _ := testutils.CreateUser("get_account_info@example.com", true)
 
@@ -0,0 +1,96 @@
+package testutils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/mattcarlotta/nvi-api/utils"
+)
+
+// a key provider that keeps count of its operations; the "wrapped" key is the data key reversed behind a prefix,
+// which is enough to tell that the provider was used without any real cryptography
+type FakeKeyProvider struct {
+	ID      string
+	Wraps   int
+	Unwraps int
+}
+
+const fakeWrappedKeyPrefix = "fake:"
+
+func (p *FakeKeyProvider) KeyID() string {
+	return p.ID
+}
+
+func (p *FakeKeyProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	p.Wraps++
+
+	wrappedKey := []byte(fakeWrappedKeyPrefix)
+	for i := len(dataKey) - 1; i >= 0; i-- {
+		wrappedKey = append(wrappedKey, dataKey[i])
+	}
+	return wrappedKey, nil
+}
+
+func (p *FakeKeyProvider) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	p.Unwraps++
+
+	reversedKey := wrappedKey[len(fakeWrappedKeyPrefix):]
+	dataKey := make([]byte, 0, len(reversedKey))
+	for i := len(reversedKey) - 1; i >= 0; i-- {
+		dataKey = append(dataKey, reversedKey[i])
+	}
+	return dataKey, nil
+}
+
+// a stand-in for a Vault transit service that wraps keys with the provided key provider and only accepts the token
+func CreateTransitServer(token string, provider utils.KeyProvider) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResponse := func(status int, body interface{}) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(body)
+		}
+
+		if r.Header.Get("X-Vault-Token") != token {
+			writeResponse(http.StatusForbidden, map[string][]string{"errors": {"permission denied"}})
+			return
+		}
+
+		var body struct {
+			Plaintext  string `json:"plaintext"`
+			Ciphertext string `json:"ciphertext"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeResponse(http.StatusBadRequest, map[string][]string{"errors": {err.Error()}})
+			return
+		}
+
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/v1/transit/encrypt/"):
+			plaintext, _ := base64.StdEncoding.DecodeString(body.Plaintext)
+			wrappedKey, err := provider.WrapKey(plaintext)
+			if err != nil {
+				writeResponse(http.StatusBadRequest, map[string][]string{"errors": {err.Error()}})
+				return
+			}
+			writeResponse(http.StatusOK, map[string]map[string]string{
+				"data": {"ciphertext": "vault:v1:" + base64.StdEncoding.EncodeToString(wrappedKey)},
+			})
+		case strings.HasPrefix(r.URL.Path, "/v1/transit/decrypt/"):
+			wrappedKey, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(body.Ciphertext, "vault:v1:"))
+			dataKey, err := provider.UnwrapKey(wrappedKey)
+			if err != nil {
+				writeResponse(http.StatusBadRequest, map[string][]string{"errors": {err.Error()}})
+				return
+			}
+			writeResponse(http.StatusOK, map[string]map[string]string{
+				"data": {"plaintext": base64.StdEncoding.EncodeToString(dataKey)},
+			})
+		default:
+			writeResponse(http.StatusNotFound, map[string][]string{"errors": {"unsupported path"}})
+		}
+	}))
+}
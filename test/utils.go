@@ -2,6 +2,8 @@ package testutils
 
 import (
 	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -91,12 +93,106 @@ func ParseSessionId(userSessionID string) uuid.UUID {
 	return parsedID
 }
 
+func GetPersonalOrganization(userID uuid.UUID) models.Organization {
+	db := database.GetConnection()
+
+	var organization models.Organization
+	if err := db.Where(&models.Organization{OwnerID: userID, Personal: true}).First(&organization).Error; err != nil {
+		log.Fatalf("unable to locate the personal organization: %v", err)
+	}
+
+	return organization
+}
+
+func CreateOrganization(name string, userSessionID string) models.Organization {
+	db := database.GetConnection()
+
+	parsedID := ParseSessionId(userSessionID)
+
+	newOrganization := models.Organization{Name: name, OwnerID: parsedID}
+	if err := db.Create(&newOrganization).Error; err != nil {
+		log.Fatalf("unable to create a new organization %s: %v", name, err)
+	}
+
+	AddOrganizationMember(newOrganization.ID, parsedID, utils.RoleOwner)
+
+	return newOrganization
+}
+
+func AddOrganizationMember(organizationID uuid.UUID, userID uuid.UUID, role string) models.OrganizationMember {
+	db := database.GetConnection()
+
+	newMember := models.OrganizationMember{OrganizationID: organizationID, UserID: userID, Role: role}
+	if err := db.Create(&newMember).Error; err != nil {
+		log.Fatalf("unable to add the organization member: %v", err)
+	}
+
+	return newMember
+}
+
+func GetOrganizationMember(organizationID uuid.UUID, userID uuid.UUID) models.OrganizationMember {
+	db := database.GetConnection()
+
+	var member models.OrganizationMember
+	if err := db.Where(
+		&models.OrganizationMember{OrganizationID: organizationID, UserID: userID},
+	).First(&member).Error; err != nil {
+		log.Fatalf("unable to locate the organization member: %v", err)
+	}
+
+	return member
+}
+
+func CreateAPIKey(newAPIKey models.APIKey) models.APIKey {
+	db := database.GetConnection()
+
+	if len(newAPIKey.Access) == 0 {
+		newAPIKey.Access = utils.AccessRead
+	}
+
+	if err := db.Create(&newAPIKey).Error; err != nil {
+		log.Fatalf("unable to create the api key: %v", err)
+	}
+
+	return newAPIKey
+}
+
+func CreateEnvironmentRestriction(environmentID uuid.UUID, role string, access string) models.EnvironmentRestriction {
+	db := database.GetConnection()
+
+	newRestriction := models.EnvironmentRestriction{EnvironmentID: environmentID, Role: role, Access: access}
+	if err := db.Create(&newRestriction).Error; err != nil {
+		log.Fatalf("unable to create the environment restriction: %v", err)
+	}
+
+	return newRestriction
+}
+
+func CreateOrganizationInvite(organizationID uuid.UUID, email string, userSessionID string) models.OrganizationInvite {
+	db := database.GetConnection()
+
+	parsedID := ParseSessionId(userSessionID)
+
+	newInvite := models.OrganizationInvite{OrganizationID: organizationID, Email: email, InvitedByID: parsedID}
+	if err := db.Create(&newInvite).Error; err != nil {
+		log.Fatalf("unable to create the organization invite: %v", err)
+	}
+
+	return newInvite
+}
+
 func CreateProject(name string, userSessionID string) models.Project {
+	parsedID := ParseSessionId(userSessionID)
+
+	return CreateOrganizationProject(name, GetPersonalOrganization(parsedID).ID, userSessionID)
+}
+
+func CreateOrganizationProject(name string, organizationID uuid.UUID, userSessionID string) models.Project {
 	db := database.GetConnection()
 
 	parsedID := ParseSessionId(userSessionID)
 
-	newProject := models.Project{Name: name, UserID: parsedID}
+	newProject := models.Project{Name: name, OrganizationID: organizationID, UserID: parsedID}
 	if err := db.Create(&newProject).Error; err != nil {
 		log.Fatalf("unable to create a new project %s: %v", name, err)
 	}
@@ -105,6 +201,39 @@ func CreateProject(name string, userSessionID string) models.Project {
 
 }
 
+func CreateEndToEndProject(name string, userSessionID string) models.Project {
+	db := database.GetConnection()
+
+	parsedID := ParseSessionId(userSessionID)
+
+	newProject := models.Project{
+		Name:           name,
+		OrganizationID: GetPersonalOrganization(parsedID).ID,
+		UserID:         parsedID,
+		EndToEnd:       true,
+	}
+	if err := db.Create(&newProject).Error; err != nil {
+		log.Fatalf("unable to create a new end-to-end encrypted project %s: %v", name, err)
+	}
+
+	return newProject
+}
+
+func CreatePublicKey(userID uuid.UUID) *ecdh.PrivateKey {
+	db := database.GetConnection()
+
+	privateKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatalf("unable to generate a key pair: %v", err)
+	}
+
+	if err := db.Model(&models.User{}).Where("id=?", userID).Update("public_key", privateKey.PublicKey().Bytes()).Error; err != nil {
+		log.Fatalf("unable to store the public key of user %s: %v", userID, err)
+	}
+
+	return privateKey
+}
+
 func CreateEnvironment(envName string, projectID uuid.UUID, userSessionID string) models.Environment {
 	db := database.GetConnection()
 
@@ -118,19 +247,62 @@ func CreateEnvironment(envName string, projectID uuid.UUID, userSessionID string
 	return newEnv
 }
 
+func SetEnvironmentParent(environment *models.Environment, parentID uuid.UUID) {
+	db := database.GetConnection()
+
+	if err := db.Model(environment).Update("parent_id", parentID).Error; err != nil {
+		log.Fatalf("unable to set the parent of environment %s: %v", environment.Name, err)
+	}
+}
+
 func CreateEnvironmentAndSecret(envName string, projectID uuid.UUID, secretKey string, secretValue string, userSessionID string) (models.Environment, models.Secret) {
 	newEnv := CreateEnvironment(envName, projectID, userSessionID)
+
+	return newEnv, CreateSecret(newEnv, secretKey, secretValue, userSessionID)
+}
+
+func CreateSecret(environment models.Environment, secretKey string, secretValue string, userSessionID string) models.Secret {
 	db := database.GetConnection()
 
 	parsedID := ParseSessionId(userSessionID)
 
-	environments := []models.Environment{newEnv}
-	secret := models.Secret{Key: secretKey, Value: []byte(secretValue), UserID: parsedID, Environments: environments}
+	secret := models.Secret{
+		Key:          secretKey,
+		Value:        []byte(secretValue),
+		ProjectID:    environment.ProjectID,
+		UserID:       parsedID,
+		Environments: []models.Environment{environment},
+	}
 	if err := db.Create(&secret).Error; err != nil {
-		log.Fatalf("unable to create new environment: %v", err)
+		log.Fatalf("unable to create new secret: %v", err)
+	}
+
+	return secret
+}
+
+func AttachSecretEnvironment(secret models.Secret, environment models.Environment) {
+	db := database.GetConnection()
+
+	if err := db.Model(&secret).Association("Environments").Append(&environment); err != nil {
+		log.Fatalf("unable to attach secret %s to environment %s: %v", secret.Key, environment.Name, err)
+	}
+}
+
+func SetSecretEnvironmentValue(secret models.Secret, environment models.Environment, secretValue string) {
+	db := database.GetConnection()
+
+	project, err := models.GetSecretProject(db, secret.ProjectID)
+	if err != nil {
+		log.Fatalf("unable to locate the project of secret %s: %v", secret.Key, err)
+	}
+
+	if err := db.Model(&secret).Association("Environments").Append(&environment); err != nil {
+		log.Fatalf("unable to attach secret %s to environment %s: %v", secret.Key, environment.Name, err)
 	}
 
-	return newEnv, secret
+	if err := models.SetEnvironmentSecretValue(db, &project, secret.ID, environment.ID, []byte(secretValue)); err != nil {
+		log.Fatalf("unable to set the value of secret %s within environment %s: %v", secret.Key, environment.Name, err)
+	}
 }
 
 func CreateProjectAndEnvironmentAndSecret(projectName string, envName string, secretKey string, secretValue string, userSessionID string) (models.Project, models.Environment, models.Secret) {
@@ -140,6 +312,48 @@ func CreateProjectAndEnvironmentAndSecret(projectName string, envName string, se
 	return newProject, newEnv, newSecret
 }
 
+func GetSecretByKey(key string, projectID uuid.UUID) models.Secret {
+	db := database.GetConnection()
+
+	var secret models.Secret
+	if err := db.Where(&models.Secret{Key: key, ProjectID: projectID}).First(&secret).Error; err != nil {
+		log.Fatalf("unable to locate secret %s: %v", key, err)
+	}
+
+	return secret
+}
+
+func GetSecretRevisions(secretID uuid.UUID) []models.SecretRevision {
+	db := database.GetConnection()
+
+	var revisions []models.SecretRevision
+	if err := db.Where(&models.SecretRevision{SecretID: secretID}).Order("version").Find(&revisions).Error; err != nil {
+		log.Fatalf("unable to locate secret revisions: %v", err)
+	}
+
+	return revisions
+}
+
+func OverwriteSecretValue(secretID uuid.UUID, value []byte, nonce []byte) {
+	db := database.GetConnection()
+
+	if err := db.Model(&models.Secret{}).Where("id=?", secretID).UpdateColumns(
+		map[string]interface{}{"value": value, "nonce": nonce},
+	).Error; err != nil {
+		log.Fatalf("unable to overwrite the value of secret %s: %v", secretID, err)
+	}
+}
+
+func DestroyProjectDataKey(projectID uuid.UUID) {
+	db := database.GetConnection()
+
+	if err := db.Model(&models.Project{}).Where("id=?", projectID).Updates(
+		map[string]interface{}{"wrapped_data_key": nil},
+	).Error; err != nil {
+		log.Fatalf("unable to destroy the data key of project %s: %v", projectID, err)
+	}
+}
+
 func CreateHTTPRequest(test *TestResponse, body ...interface{}) *http.Request {
 	var bodyBuf bytes.Buffer
 	if body != nil {
@@ -163,6 +377,13 @@ func CreateAuthHTTPRequest(test *TestResponse, token *string, body ...interface{
 	return req
 }
 
+func CreateAPIKeyHTTPRequest(test *TestResponse, apiKey string, body ...interface{}) *http.Request {
+	req := CreateHTTPRequest(test, body...)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+
+	return req
+}
+
 // func ParseJSONSuccessBody(body *io.ReadCloser) utils.ResponseError {
 // 	var res utils.ResponseError
 // 	responseBodyBytes, _ := io.ReadAll(*body)
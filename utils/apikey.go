@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+)
+
+const APIKeyPrefixLength = 8
+
+// api keys are random and long enough that a fast hash is sufficient
+func HashAPIKey(key string) []byte {
+	hash := sha256.Sum256([]byte(key))
+	return hash[:]
+}
+
+func CompareAPIKeyHash(hash []byte, key string) bool {
+	return subtle.ConstantTimeCompare(hash, HashAPIKey(key)) == 1
+}
+
+func GetAPIKeyPrefix(key string) string {
+	if len(key) < APIKeyPrefixLength {
+		return key
+	}
+	return key[:APIKeyPrefixLength]
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"os"
+)
+
+// AUDIT_CHAIN_KEY keeps the chain valid when the JWT secret is rotated
+var AUDIT_CHAIN_KEY = getAuditChainKey()
+
+func getAuditChainKey() []byte {
+	if key, ok := os.LookupEnv("AUDIT_CHAIN_KEY"); ok && len(key) > 0 {
+		return []byte(key)
+	}
+	return JWT_SECRET_KEY
+}
+
+func HashAuditEvent(prevHash []byte, contents []byte) []byte {
+	mac := hmac.New(sha256.New, AUDIT_CHAIN_KEY)
+	mac.Write(prevHash)
+	mac.Write(contents)
+	return mac.Sum(nil)
+}
+
+func CompareAuditHash(a []byte, b []byte) bool {
+	return hmac.Equal(a, b)
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	SecretDiffAdded   = "added"
+	SecretDiffRemoved = "removed"
+	SecretDiffChanged = "changed"
+	SecretDiffSame    = "unchanged"
+)
+
+type SecretDiff struct {
+	Key    string  `json:"key"`
+	Status string  `json:"status"`
+	From   *string `json:"from"`
+	To     *string `json:"to"`
+}
+
+// values are only returned through present, never as is
+func DiffSecretValues(from map[string]string, to map[string]string, present func(string) string) []SecretDiff {
+	keys := make([]string, 0, len(from)+len(to))
+	for key := range from {
+		keys = append(keys, key)
+	}
+	for key := range to {
+		if _, ok := from[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	diffs := make([]SecretDiff, 0, len(keys))
+	for _, key := range keys {
+		diff := SecretDiff{Key: key}
+
+		fromValue, inFrom := from[key]
+		if inFrom {
+			presented := present(fromValue)
+			diff.From = &presented
+		}
+
+		toValue, inTo := to[key]
+		if inTo {
+			presented := present(toValue)
+			diff.To = &presented
+		}
+
+		switch {
+		case !inFrom:
+			diff.Status = SecretDiffAdded
+		case !inTo:
+			diff.Status = SecretDiffRemoved
+		case fromValue != toValue:
+			diff.Status = SecretDiffChanged
+		default:
+			diff.Status = SecretDiffSame
+		}
+
+		diffs = append(diffs, diff)
+	}
+
+	return diffs
+}
+
+func MaskSecretValue(value string) string {
+	if len(value) < 12 {
+		return strings.Repeat("*", 8)
+	}
+	return value[:2] + strings.Repeat("*", 8) + value[len(value)-2:]
+}
+
+// the key is unique to the hasher so hashes can't be compared across diffs or with guesses
+func NewSecretValueHasher() (func(string) string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return func(value string) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(value))
+		return hex.EncodeToString(mac.Sum(nil))
+	}, nil
+}
+
+func FormatSecretDiffs(diffs []SecretDiff) string {
+	var formatted strings.Builder
+	for _, diff := range diffs {
+		switch diff.Status {
+		case SecretDiffAdded:
+			fmt.Fprintf(&formatted, "+ %s = %s\n", diff.Key, *diff.To)
+		case SecretDiffRemoved:
+			fmt.Fprintf(&formatted, "- %s = %s\n", diff.Key, *diff.From)
+		case SecretDiffChanged:
+			fmt.Fprintf(&formatted, "~ %s = %s -> %s\n", diff.Key, *diff.From, *diff.To)
+		}
+	}
+	return formatted.String()
+}
@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type EnvVar struct {
+	Key   string
+	Value string
+}
+
+type EnvVars []EnvVar
+
+// a repeated key overrides the previous value, the same way a dotenv loader would
+func (vars *EnvVars) Set(key string, value string) {
+	for i, envVar := range *vars {
+		if envVar.Key == key {
+			(*vars)[i].Value = value
+			return
+		}
+	}
+	*vars = append(*vars, EnvVar{Key: key, Value: value})
+}
+
+var dotenvKeyRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.\-]*$`)
+
+var dotenvUnquotedValueRegex = regexp.MustCompile(`^[a-zA-Z0-9_./:@%+,\-]*$`)
+
+var dotenvDoubleQuoteEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"$", `\$`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\t", `\t`,
+)
+
+var dotenvEscapes = map[byte]string{
+	'n':  "\n",
+	'r':  "\r",
+	't':  "\t",
+	'"':  `"`,
+	'\\': `\`,
+	'$':  "$",
+}
+
+func ParseDotenv(content string) (EnvVars, error) {
+	var vars EnvVars
+
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	line := 1
+	pos := 0
+	for pos < len(content) {
+		end := strings.IndexByte(content[pos:], '\n')
+		if end == -1 {
+			end = len(content)
+		} else {
+			end += pos
+		}
+
+		trimmed := strings.TrimSpace(content[pos:end])
+		if len(trimmed) == 0 || strings.HasPrefix(trimmed, "#") {
+			pos = end + 1
+			line++
+			continue
+		}
+
+		separator := strings.IndexByte(trimmed, '=')
+		if separator == -1 {
+			return nil, fmt.Errorf("line %d is missing a '=' separator", line)
+		}
+
+		key := strings.TrimSpace(trimmed[:separator])
+		if strings.HasPrefix(key, "export ") {
+			key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+		}
+		if !dotenvKeyRegex.MatchString(key) {
+			return nil, fmt.Errorf("line %d contains an invalid key '%s'", line, key)
+		}
+
+		valueStart := strings.Index(content[pos:end], "=") + pos + 1
+		for valueStart < end && (content[valueStart] == ' ' || content[valueStart] == '\t') {
+			valueStart++
+		}
+
+		if valueStart < end && strings.ContainsRune("\"'`", rune(content[valueStart])) {
+			value, next, lines, err := parseQuotedDotenvValue(content, valueStart)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", line, err.Error())
+			}
+
+			rest := next
+			for rest < len(content) && content[rest] != '\n' {
+				rest++
+			}
+			if remainder := strings.TrimSpace(content[next:rest]); len(remainder) > 0 && !strings.HasPrefix(remainder, "#") {
+				return nil, fmt.Errorf("line %d contains unexpected characters after the closing quote", line+lines)
+			}
+
+			vars.Set(key, value)
+			pos = rest + 1
+			line += lines + 1
+			continue
+		}
+
+		value := content[valueStart:end]
+		if strings.HasPrefix(value, "#") {
+			value = ""
+		}
+		if comment := strings.Index(value, " #"); comment != -1 {
+			value = value[:comment]
+		}
+		if comment := strings.Index(value, "\t#"); comment != -1 {
+			value = value[:comment]
+		}
+
+		vars.Set(key, strings.TrimSpace(value))
+		pos = end + 1
+		line++
+	}
+
+	return vars, nil
+}
+
+func parseQuotedDotenvValue(content string, start int) (string, int, int, error) {
+	quote := content[start]
+
+	var value strings.Builder
+	lines := 0
+	for i := start + 1; i < len(content); i++ {
+		char := content[i]
+		switch {
+		case char == quote:
+			return value.String(), i + 1, lines, nil
+		case char == '\\' && quote == '"' && i+1 < len(content):
+			if escaped, ok := dotenvEscapes[content[i+1]]; ok {
+				value.WriteString(escaped)
+				i++
+				continue
+			}
+			value.WriteByte(char)
+		default:
+			if char == '\n' {
+				lines++
+			}
+			value.WriteByte(char)
+		}
+	}
+
+	return "", 0, 0, fmt.Errorf("the value is missing a closing %c quote", quote)
+}
+
+// values with special characters are single quoted, and ones with single quotes or newlines are double quoted
+// with escapes (including "\$") so that they're never interpolated
+func MarshalDotenv(vars EnvVars) (string, error) {
+	var output strings.Builder
+	for _, envVar := range vars {
+		if !dotenvKeyRegex.MatchString(envVar.Key) {
+			return "", fmt.Errorf("the key '%s' is not a valid dotenv key", envVar.Key)
+		}
+
+		value := envVar.Value
+		switch {
+		case dotenvUnquotedValueRegex.MatchString(value):
+		case !strings.ContainsAny(value, "'\n\r"):
+			value = "'" + value + "'"
+		default:
+			value = `"` + dotenvDoubleQuoteEscaper.Replace(value) + `"`
+		}
+
+		output.WriteString(envVar.Key + "=" + value + "\n")
+	}
+
+	return output.String(), nil
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalDotenvRoundTrip(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"abc123", "KEY=abc123\n"},
+		{"", "KEY=\n"},
+		{"postgres://user@localhost:5432/db", "KEY=postgres://user@localhost:5432/db\n"},
+		{"hello world", "KEY='hello world'\n"},
+		{"abc#123", "KEY='abc#123'\n"},
+		{"value # not a comment", "KEY='value # not a comment'\n"},
+		{"${USER}", "KEY='${USER}'\n"},
+		{"$HOME", "KEY='$HOME'\n"},
+		{`say "hello"`, `KEY='say "hello"'` + "\n"},
+		{`C:\path\to`, `KEY='C:\path\to'` + "\n"},
+		{"it's", `KEY="it's"` + "\n"},
+		{"line 1\nline 2", `KEY="line 1\nline 2"` + "\n"},
+		{"it's $HOME", `KEY="it's \$HOME"` + "\n"},
+		{"it's \"quoted\"\\", `KEY="it's \"quoted\"\\"` + "\n"},
+		{"tab\there\r\n", `KEY="tab\there\r\n"` + "\n"},
+	}
+
+	for _, test := range tests {
+		output, err := MarshalDotenv(EnvVars{{Key: "KEY", Value: test.value}})
+		assert.Nil(t, err, test.value)
+		assert.Equal(t, test.expected, output, test.value)
+
+		vars, err := ParseDotenv(output)
+		assert.Nil(t, err, test.value)
+		assert.Equal(t, EnvVars{{Key: "KEY", Value: test.value}}, vars, test.value)
+	}
+}
+
+func TestMarshalDotenvInvalidKey(t *testing.T) {
+	for _, key := range []string{"INVALID KEY", "1KEY", "KEY=VALUE", ""} {
+		_, err := MarshalDotenv(EnvVars{{Key: key, Value: "abc123"}})
+		assert.NotNil(t, err, key)
+	}
+}
+
+func TestParseDotenv(t *testing.T) {
+	vars, err := ParseDotenv("# comment\nexport USER=admin\nPASSWORD=abc123 # comment\nEMPTY=\n\nMULTILINE=\"line 1\nline 2\"\nLITERAL='$USER'\nUSER=root\n")
+
+	assert.Nil(t, err)
+	assert.Equal(t, EnvVars{
+		{Key: "USER", Value: "root"},
+		{Key: "PASSWORD", Value: "abc123"},
+		{Key: "EMPTY", Value: ""},
+		{Key: "MULTILINE", Value: "line 1\nline 2"},
+		{Key: "LITERAL", Value: "$USER"},
+	}, vars)
+}
@@ -89,3 +89,23 @@ func SendPasswordResetConfirmationEmail(name string, address string) error {
 
 	return email.send()
 }
+
+func SendOrganizationInviteEmail(name string, address string, organizationName string, invitedBy string, token string) error {
+	if GetEnv("IN_TESTING") != "false" {
+		return nil
+	}
+
+	email := CustomEmail{
+		M:          mail.NewV3Mail(),
+		P:          mail.NewPersonalization(),
+		Name:       name,
+		Address:    address,
+		TemplateID: GetEnv("SEND_GRID_ORGANIZATION_INVITE_TEMPLATE_ID"),
+	}
+
+	email.setTemplateData("organization", organizationName)
+	email.setTemplateData("invited_by", invitedBy)
+	email.setTemplateData("invite_link", GetEnv("CLIENT_HOST")+"/invite?token="+token)
+
+	return email.send()
+}
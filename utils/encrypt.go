@@ -5,13 +5,18 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"errors"
+	"fmt"
 	"io"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
 )
 
-var ENCRYPTION_KEY = []byte(GetEnv("ENCRYPTION_KEY"))
+const DataKeySize = 32
 
-func CreateEncryptedSecretValue(plaintext []byte) ([]byte, []byte, error) {
-	block, err := aes.NewCipher(ENCRYPTION_KEY)
+func seal(key []byte, plaintext []byte, associatedData []byte) ([]byte, []byte, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -26,17 +31,17 @@ func CreateEncryptedSecretValue(plaintext []byte) ([]byte, []byte, error) {
 		return nil, nil, err
 	}
 
-	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+	ciphertext := gcm.Seal(nil, nonce, plaintext, associatedData)
 
 	return ciphertext, nonce, nil
 }
 
-func DecryptSecretValue(data []byte, nonce []byte) ([]byte, error) {
+func open(key []byte, data []byte, nonce []byte, associatedData []byte) ([]byte, error) {
 	if len(nonce) == 0 {
 		return nil, errors.New("the provided nonce is not valid because it has no length")
 	}
 
-	block, err := aes.NewCipher(ENCRYPTION_KEY)
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
@@ -46,5 +51,91 @@ func DecryptSecretValue(data []byte, nonce []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	return gcm.Open(nil, nonce, data, nil)
+	return gcm.Open(nil, nonce, data, associatedData)
+}
+
+func CreateDataKey() (dataKey []byte, wrappedKey []byte, keyID string, err error) {
+	dataKey = make([]byte, DataKeySize)
+	if _, err = io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, nil, "", err
+	}
+
+	provider := ActiveKeyProvider
+	wrappedKey, err = provider.WrapKey(dataKey)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	return dataKey, wrappedKey, provider.KeyID(), nil
+}
+
+func UnwrapDataKey(keyID string, wrappedKey []byte) ([]byte, error) {
+	if provider, ok := KeyProviders[keyID]; ok {
+		return provider.UnwrapKey(wrappedKey)
+	}
+
+	// unqualified key IDs predate provider prefixes; the key rotation re-wraps them
+	if !strings.Contains(keyID, ":") {
+		for id, provider := range KeyProviders {
+			if _, unqualifiedID, _ := strings.Cut(id, ":"); unqualifiedID != keyID {
+				continue
+			}
+
+			if dataKey, err := provider.UnwrapKey(wrappedKey); err == nil {
+				return dataKey, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("the '%s' master key isn't available, so the data key can't be unwrapped", keyID)
+}
+
+func RewrapDataKey(keyID string, wrappedKey []byte) ([]byte, string, error) {
+	dataKey, err := UnwrapDataKey(keyID, wrappedKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	provider := ActiveKeyProvider
+	rewrappedKey, err := provider.WrapKey(dataKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return rewrappedKey, provider.KeyID(), nil
+}
+
+// values with a seal version of 0 have no associated data
+const SecretSealVersion = 1
+
+// a seal version of 0 after migrating means the value was copied in from elsewhere
+var ErrUnboundSecretValue = errors.New("the secret value isn't bound to its secret")
+
+// a ciphertext copied onto another secret fails to decrypt rather than being served under the wrong key
+func SecretAssociatedData(sealVersion int, secretID uuid.UUID, projectID uuid.UUID, key string) []byte {
+	if sealVersion == 0 {
+		return nil
+	}
+	return []byte(fmt.Sprintf("nvi:secret:v%d\n%s\n%s\n%s", sealVersion, secretID, projectID, key))
+}
+
+// the key is left out so renaming a secret doesn't require re-sealing its environment values
+func SecretEnvironmentAssociatedData(sealVersion int, secretID uuid.UUID, environmentID uuid.UUID, projectID uuid.UUID) []byte {
+	return []byte(fmt.Sprintf("nvi:secret-environment:v%d\n%s\n%s\n%s", sealVersion, secretID, environmentID, projectID))
+}
+
+func OpenMasterKeySealedValue(data []byte, nonce []byte) ([]byte, error) {
+	key, ok := os.LookupEnv("ENCRYPTION_KEY")
+	if !ok || len(key) == 0 {
+		return nil, errors.New("the value was sealed with the ENCRYPTION_KEY, which isn't set")
+	}
+	return open([]byte(key), data, nonce, nil)
+}
+
+func CreateEncryptedSecretValue(dataKey []byte, plaintext []byte, associatedData []byte) ([]byte, []byte, error) {
+	return seal(dataKey, plaintext, associatedData)
+}
+
+func DecryptSecretValue(dataKey []byte, data []byte, nonce []byte, associatedData []byte) ([]byte, error) {
+	return open(dataKey, data, nonce, associatedData)
 }
@@ -66,66 +66,240 @@ const (
 	CreateProjectOverLimit
 	CreateEnvironmentOverLimit
 	UpdateDisplayNameMissingName
+	GetSecretRevisionsInvalidID
+	GetSecretRevisionsNonExistentID
+	GetSecretRevisionInvalidID
+	GetSecretRevisionNonExistentID
+	RestoreSecretRevisionInvalidID
+	RestoreSecretRevisionNonExistentID
+	RestoreSecretRevisionNonExistentEnv
+	RestoreSecretRevisionKeyAlreadyExists
+	ImportSecretsInvalidBody
+	ImportSecretsNonExistentProject
+	ImportSecretsNonExistentEnv
+	ImportSecretsInvalidContent
+	ImportSecretsInvalidSecret
+	ImportSecretsKeyAlreadyExists
+	GetOrganizationInvalidID
+	GetOrganizationNonExistentID
+	CreateOrganizationInvalidName
+	CreateOrganizationNameTaken
+	UpdateOrganizationInvalidBody
+	UpdateOrganizationNonExistentID
+	UpdateOrganizationNameTaken
+	DeleteOrganizationInvalidID
+	DeleteOrganizationNonExistentID
+	DeleteOrganizationPersonal
+	InviteMemberInvalidBody
+	InviteMemberNonExistentOrganization
+	InviteMemberAlreadyMember
+	AcceptInviteInvalidToken
+	AcceptInviteNonExistentInvite
+	RemoveMemberInvalidID
+	RemoveMemberNonExistentID
+	RemoveMemberOwner
+	CreateProjectNonExistentOrganization
+	PermissionDenied
+	EnvironmentPermissionDenied
+	UpdateMemberRoleInvalidBody
+	UpdateMemberRoleNonExistentID
+	UpdateMemberRoleOwner
+	GetEnvironmentRestrictionsInvalidID
+	GetEnvironmentRestrictionsNonExistentID
+	UpdateEnvironmentRestrictionInvalidBody
+	UpdateEnvironmentRestrictionNonExistentEnv
+	CreateAPIKeyInvalidBody
+	CreateAPIKeyNameTaken
+	CreateAPIKeyNonExistentProject
+	CreateAPIKeyNonExistentEnv
+	RevokeAPIKeyInvalidID
+	RevokeAPIKeyNonExistentID
+	GetAuditEventsInvalidID
+	GetAuditEventsInvalidQuery
+	GetAuditEventsNonExistentProject
+	EndToEndUnsupported
+	UpdatePublicKeyInvalidBody
+	GetKeyShareInvalidID
+	GetKeyShareNonExistentShare
+	GetProjectPublicKeysInvalidID
+	GetProjectPublicKeysNonExistentProject
+	UpdateKeyShareInvalidBody
+	UpdateKeyShareNonExistentProject
+	UpdateKeyShareNotEndToEnd
+	UpdateKeyShareNonExistentMember
+	SecretReferenceCycle
+	SecretReferenceDangling
+	CreateEnvironmentNonExistentParent
+	UpdateEnvironmentParentInvalidBody
+	UpdateEnvironmentParentNonExistentID
+	UpdateEnvironmentParentNonExistentParent
+	UpdateEnvironmentParentCycle
+	CloneEnvironmentInvalidBody
+	CloneEnvironmentNonExistentID
+	CloneEnvironmentNameTaken
+	CloneEnvironmentOverLimit
+	PromoteEnvironmentInvalidBody
+	PromoteEnvironmentNonExistentID
+	PromoteEnvironmentSameEnvironment
+	PromoteEnvironmentOverLimit
+	DiffSecretsInvalidQuery
+	DiffSecretsNonExistentEnvironment
+	UpdateSecretEnvValueInvalidBody
+	UpdateSecretEnvValueNonExistentID
+	UpdateSecretEnvValueNonExistentEnv
+	UpdateSecretEnvValueKeyAlreadyExists
+	PreconditionFailed
+	StreamEnvironmentEventsInvalidID
+	StreamEnvironmentEventsNonExistentID
+	VerifyAuditChainInvalidID
+	VerifyAuditChainNonExistentProject
 )
 
 var ErrorCode = map[ErrorResponseCode]string{
-	Unknown:                                  "E000",
-	RegisterInvalidBody:                      "E001",
-	RegisterEmailTaken:                       "E002",
-	LoginInvalidBody:                         "E003",
-	LoginUnregisteredEmail:                   "E004",
-	LoginInvalidPassword:                     "E005",
-	LoginAccountNotVerified:                  "E006",
-	VerifyAccountInvalidToken:                "E007",
-	ResendAccountVerificationInvalidEmail:    "E008",
-	SendResetPasswordInvalidEmail:            "E009",
-	UpdatePasswordInvalidBody:                "E010",
-	UpdatePasswordInvalidToken:               "E011",
-	GetAllEnvironmentsInvalidProjectID:       "E012",
-	GetAllEnvironmentsNonExistentID:          "E013",
-	GetEnvironmentInvalidID:                  "E012",
-	GetEnvironmentNonExistentID:              "E013",
-	GetEnvironmentInvalidName:                "E014",
-	GetEnvironmentInvalidProjectID:           "E015",
-	GetEnvironmentNonExistentName:            "E016",
-	CreateEnvironmentInvalidBody:             "E017",
-	CreateEnvironmentInvalidProjectID:        "E018",
-	CreateEnvironmentNameTaken:               "E019",
-	DeleteEnvironmentInvalidID:               "E020",
-	DeleteEnvironmentNonExistentID:           "E021",
-	UpdateEnvironmentInvalidBody:             "E022",
-	UpdateEnvironmentInvalidProjectID:        "E023",
-	UpdateEnvironmentNonExistentID:           "E024",
-	UpdateEnvironmentNameTaken:               "E025",
-	GetSecretInvalidID:                       "E026",
-	GetSecretNonExistentID:                   "E027",
-	GetSecretsByEnvInvalidID:                 "E028",
-	GetSecretsByEnvNonExistentID:             "E029",
-	CreateSecretInvalidBody:                  "E030",
-	CreateSecretNonExistentProject:           "E031",
-	CreateSecretNonExistentEnv:               "E032",
-	CreateSecretKeyAlreadyExists:             "E033",
-	DeleteSecretInvalidID:                    "E034",
-	DeleteSecretNonExistentID:                "E035",
-	UpdateSecretInvalidBody:                  "E036",
-	UpdateSecretInvalidID:                    "E037",
-	UpdateSecretNonExistentEnv:               "E038",
-	UpdateSecretKeyAlreadyExists:             "E039",
-	GetProjectInvalidID:                      "E040",
-	GetProjectNonExistentID:                  "E041",
-	GetProjectInvalidName:                    "E042",
-	GetProjectNonExistentName:                "E043",
-	CreateProjectInvalidName:                 "E044",
-	CreateProjectNameTaken:                   "E045",
-	DeleteProjectInvalidID:                   "E046",
-	DeleteProjectNonExistentID:               "E047",
-	UpdateProjectInvalidBody:                 "E048",
-	UpdateProjectNonExistentID:               "E049",
-	UpdateProjectNameTaken:                   "E050",
-	SearchForSecretsByEnvAndSecretInvalidKey: "E051",
-	CreateProjectOverLimit:                   "E052",
-	CreateEnvironmentOverLimit:               "E053",
-	UpdateDisplayNameMissingName:             "E054",
+	Unknown:                                    "E000",
+	RegisterInvalidBody:                        "E001",
+	RegisterEmailTaken:                         "E002",
+	LoginInvalidBody:                           "E003",
+	LoginUnregisteredEmail:                     "E004",
+	LoginInvalidPassword:                       "E005",
+	LoginAccountNotVerified:                    "E006",
+	VerifyAccountInvalidToken:                  "E007",
+	ResendAccountVerificationInvalidEmail:      "E008",
+	SendResetPasswordInvalidEmail:              "E009",
+	UpdatePasswordInvalidBody:                  "E010",
+	UpdatePasswordInvalidToken:                 "E011",
+	GetAllEnvironmentsInvalidProjectID:         "E012",
+	GetAllEnvironmentsNonExistentID:            "E013",
+	GetEnvironmentInvalidID:                    "E012",
+	GetEnvironmentNonExistentID:                "E013",
+	GetEnvironmentInvalidName:                  "E014",
+	GetEnvironmentInvalidProjectID:             "E015",
+	GetEnvironmentNonExistentName:              "E016",
+	CreateEnvironmentInvalidBody:               "E017",
+	CreateEnvironmentInvalidProjectID:          "E018",
+	CreateEnvironmentNameTaken:                 "E019",
+	DeleteEnvironmentInvalidID:                 "E020",
+	DeleteEnvironmentNonExistentID:             "E021",
+	UpdateEnvironmentInvalidBody:               "E022",
+	UpdateEnvironmentInvalidProjectID:          "E023",
+	UpdateEnvironmentNonExistentID:             "E024",
+	UpdateEnvironmentNameTaken:                 "E025",
+	GetSecretInvalidID:                         "E026",
+	GetSecretNonExistentID:                     "E027",
+	GetSecretsByEnvInvalidID:                   "E028",
+	GetSecretsByEnvNonExistentID:               "E029",
+	CreateSecretInvalidBody:                    "E030",
+	CreateSecretNonExistentProject:             "E031",
+	CreateSecretNonExistentEnv:                 "E032",
+	CreateSecretKeyAlreadyExists:               "E033",
+	DeleteSecretInvalidID:                      "E034",
+	DeleteSecretNonExistentID:                  "E035",
+	UpdateSecretInvalidBody:                    "E036",
+	UpdateSecretInvalidID:                      "E037",
+	UpdateSecretNonExistentEnv:                 "E038",
+	UpdateSecretKeyAlreadyExists:               "E039",
+	GetProjectInvalidID:                        "E040",
+	GetProjectNonExistentID:                    "E041",
+	GetProjectInvalidName:                      "E042",
+	GetProjectNonExistentName:                  "E043",
+	CreateProjectInvalidName:                   "E044",
+	CreateProjectNameTaken:                     "E045",
+	DeleteProjectInvalidID:                     "E046",
+	DeleteProjectNonExistentID:                 "E047",
+	UpdateProjectInvalidBody:                   "E048",
+	UpdateProjectNonExistentID:                 "E049",
+	UpdateProjectNameTaken:                     "E050",
+	SearchForSecretsByEnvAndSecretInvalidKey:   "E051",
+	CreateProjectOverLimit:                     "E052",
+	CreateEnvironmentOverLimit:                 "E053",
+	UpdateDisplayNameMissingName:               "E054",
+	GetSecretRevisionsInvalidID:                "E055",
+	GetSecretRevisionsNonExistentID:            "E056",
+	GetSecretRevisionInvalidID:                 "E057",
+	GetSecretRevisionNonExistentID:             "E058",
+	RestoreSecretRevisionInvalidID:             "E059",
+	RestoreSecretRevisionNonExistentID:         "E060",
+	RestoreSecretRevisionNonExistentEnv:        "E061",
+	RestoreSecretRevisionKeyAlreadyExists:      "E062",
+	ImportSecretsInvalidBody:                   "E063",
+	ImportSecretsNonExistentProject:            "E064",
+	ImportSecretsNonExistentEnv:                "E065",
+	ImportSecretsInvalidContent:                "E066",
+	ImportSecretsInvalidSecret:                 "E067",
+	ImportSecretsKeyAlreadyExists:              "E068",
+	GetOrganizationInvalidID:                   "E069",
+	GetOrganizationNonExistentID:               "E070",
+	CreateOrganizationInvalidName:              "E071",
+	CreateOrganizationNameTaken:                "E072",
+	UpdateOrganizationInvalidBody:              "E073",
+	UpdateOrganizationNonExistentID:            "E074",
+	UpdateOrganizationNameTaken:                "E075",
+	DeleteOrganizationInvalidID:                "E076",
+	DeleteOrganizationNonExistentID:            "E077",
+	DeleteOrganizationPersonal:                 "E078",
+	InviteMemberInvalidBody:                    "E079",
+	InviteMemberNonExistentOrganization:        "E080",
+	InviteMemberAlreadyMember:                  "E081",
+	AcceptInviteInvalidToken:                   "E082",
+	AcceptInviteNonExistentInvite:              "E083",
+	RemoveMemberInvalidID:                      "E084",
+	RemoveMemberNonExistentID:                  "E085",
+	RemoveMemberOwner:                          "E086",
+	CreateProjectNonExistentOrganization:       "E087",
+	PermissionDenied:                           "E088",
+	EnvironmentPermissionDenied:                "E089",
+	UpdateMemberRoleInvalidBody:                "E090",
+	UpdateMemberRoleNonExistentID:              "E091",
+	UpdateMemberRoleOwner:                      "E092",
+	GetEnvironmentRestrictionsInvalidID:        "E093",
+	GetEnvironmentRestrictionsNonExistentID:    "E094",
+	UpdateEnvironmentRestrictionInvalidBody:    "E095",
+	UpdateEnvironmentRestrictionNonExistentEnv: "E096",
+	CreateAPIKeyInvalidBody:                    "E097",
+	CreateAPIKeyNameTaken:                      "E098",
+	CreateAPIKeyNonExistentProject:             "E099",
+	CreateAPIKeyNonExistentEnv:                 "E100",
+	RevokeAPIKeyInvalidID:                      "E101",
+	RevokeAPIKeyNonExistentID:                  "E102",
+	GetAuditEventsInvalidID:                    "E103",
+	GetAuditEventsInvalidQuery:                 "E104",
+	GetAuditEventsNonExistentProject:           "E105",
+	EndToEndUnsupported:                        "E106",
+	UpdatePublicKeyInvalidBody:                 "E107",
+	GetKeyShareInvalidID:                       "E108",
+	GetKeyShareNonExistentShare:                "E109",
+	GetProjectPublicKeysInvalidID:              "E110",
+	GetProjectPublicKeysNonExistentProject:     "E111",
+	UpdateKeyShareInvalidBody:                  "E112",
+	UpdateKeyShareNonExistentProject:           "E113",
+	UpdateKeyShareNotEndToEnd:                  "E114",
+	UpdateKeyShareNonExistentMember:            "E115",
+	SecretReferenceCycle:                       "E116",
+	SecretReferenceDangling:                    "E117",
+	CreateEnvironmentNonExistentParent:         "E118",
+	UpdateEnvironmentParentInvalidBody:         "E119",
+	UpdateEnvironmentParentNonExistentID:       "E120",
+	UpdateEnvironmentParentNonExistentParent:   "E121",
+	UpdateEnvironmentParentCycle:               "E122",
+	CloneEnvironmentInvalidBody:                "E123",
+	CloneEnvironmentNonExistentID:              "E124",
+	CloneEnvironmentNameTaken:                  "E125",
+	CloneEnvironmentOverLimit:                  "E126",
+	PromoteEnvironmentInvalidBody:              "E127",
+	PromoteEnvironmentNonExistentID:            "E128",
+	PromoteEnvironmentSameEnvironment:          "E129",
+	PromoteEnvironmentOverLimit:                "E130",
+	DiffSecretsInvalidQuery:                    "E131",
+	DiffSecretsNonExistentEnvironment:          "E132",
+	UpdateSecretEnvValueInvalidBody:            "E133",
+	UpdateSecretEnvValueNonExistentID:          "E134",
+	UpdateSecretEnvValueNonExistentEnv:         "E135",
+	UpdateSecretEnvValueKeyAlreadyExists:       "E136",
+	PreconditionFailed:                         "E137",
+	StreamEnvironmentEventsInvalidID:           "E138",
+	StreamEnvironmentEventsNonExistentID:       "E139",
+	VerifyAuditChainInvalidID:                  "E140",
+	VerifyAuditChainNonExistentProject:         "E141",
 }
 
 type ResponseError struct {
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// weak tags are compared by value and an unquoted version is accepted as its tag
+func MatchesETag(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+
+		tag = strings.TrimPrefix(tag, "W/")
+		if strings.Trim(tag, `"`) == strings.Trim(etag, `"`) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// a request without an If-Match header is always allowed
+func IfMatch(c *fiber.Ctx, version int) bool {
+	header := c.Get(fiber.HeaderIfMatch)
+	return len(header) == 0 || MatchesETag(header, ETag(version))
+}
+
+func HashETag(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+func SetETag(c *fiber.Ctx, version int) {
+	c.Set(fiber.HeaderETag, ETag(version))
+}
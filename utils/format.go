@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var SecretFormats = []string{"dotenv", "json", "yaml", "shell", "docker", "kubernetes"}
+
+var shellVariableRegex = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+
+var kubernetesKeyRegex = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+var kubernetesNameNormalizer = strings.NewReplacer("_", "-")
+
+func FormatSecrets(format string, vars EnvVars, name string) (string, string, error) {
+	switch format {
+	case "", "dotenv":
+		output, err := MarshalDotenv(vars)
+		return output, fiber.MIMETextPlainCharsetUTF8, err
+	case "json":
+		output, err := formatJSON(vars)
+		return output, fiber.MIMEApplicationJSONCharsetUTF8, err
+	case "yaml":
+		output, err := formatYAML(vars)
+		return output, "application/yaml; charset=utf-8", err
+	case "shell":
+		output, err := formatShell(vars)
+		return output, fiber.MIMETextPlainCharsetUTF8, err
+	case "docker":
+		output, err := formatDocker(vars)
+		return output, fiber.MIMETextPlainCharsetUTF8, err
+	case "kubernetes":
+		output, err := formatKubernetes(vars, name)
+		return output, "application/yaml; charset=utf-8", err
+	default:
+		return "", "", fmt.Errorf("the '%s' format is not supported", format)
+	}
+}
+
+func formatJSON(vars EnvVars) (string, error) {
+	var output bytes.Buffer
+	output.WriteString("{")
+	for i, envVar := range vars {
+		key, err := json.Marshal(envVar.Key)
+		if err != nil {
+			return "", err
+		}
+
+		value, err := json.Marshal(envVar.Value)
+		if err != nil {
+			return "", err
+		}
+
+		if i > 0 {
+			output.WriteString(",")
+		}
+		output.WriteString("\n  ")
+		output.Write(key)
+		output.WriteString(": ")
+		output.Write(value)
+	}
+	output.WriteString("\n}\n")
+
+	return output.String(), nil
+}
+
+func yamlMapping(vars EnvVars, encodeValue func(string) string) *yaml.Node {
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	for _, envVar := range vars {
+		mapping.Content = append(
+			mapping.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: envVar.Key},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: encodeValue(envVar.Value)},
+		)
+	}
+
+	return mapping
+}
+
+func encodeYAML(node *yaml.Node) (string, error) {
+	var output bytes.Buffer
+	encoder := yaml.NewEncoder(&output)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+
+	return output.String(), nil
+}
+
+func formatYAML(vars EnvVars) (string, error) {
+	return encodeYAML(yamlMapping(vars, func(value string) string { return value }))
+}
+
+func formatShell(vars EnvVars) (string, error) {
+	var output strings.Builder
+	for _, envVar := range vars {
+		if !shellVariableRegex.MatchString(envVar.Key) {
+			return "", fmt.Errorf("the key '%s' is not a valid shell variable name", envVar.Key)
+		}
+		output.WriteString("export " + envVar.Key + "='" + strings.ReplaceAll(envVar.Value, "'", `'\''`) + "'\n")
+	}
+
+	return output.String(), nil
+}
+
+// docker env files can't quote values or represent newlines
+func formatDocker(vars EnvVars) (string, error) {
+	var output strings.Builder
+	for _, envVar := range vars {
+		if strings.ContainsAny(envVar.Value, "\n\r") {
+			return "", fmt.Errorf("the value of '%s' contains a newline, which isn't supported by docker env files", envVar.Key)
+		}
+		output.WriteString(envVar.Key + "=" + envVar.Value + "\n")
+	}
+
+	return output.String(), nil
+}
+
+func formatKubernetes(vars EnvVars, name string) (string, error) {
+	for _, envVar := range vars {
+		if !kubernetesKeyRegex.MatchString(envVar.Key) {
+			return "", fmt.Errorf("the key '%s' is not a valid kubernetes secret key", envVar.Key)
+		}
+	}
+
+	str := func(value string) *yaml.Node {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	}
+
+	manifest := &yaml.Node{
+		Kind: yaml.MappingNode,
+		Content: []*yaml.Node{
+			str("apiVersion"), str("v1"),
+			str("kind"), str("Secret"),
+			str("metadata"), {
+				Kind:    yaml.MappingNode,
+				Content: []*yaml.Node{str("name"), str(strings.Trim(strings.ToLower(kubernetesNameNormalizer.Replace(name)), "-"))},
+			},
+			str("type"), str("Opaque"),
+			str("data"), yamlMapping(vars, func(value string) string {
+				return base64.StdEncoding.EncodeToString([]byte(value))
+			}),
+		},
+	}
+
+	return encodeYAML(manifest)
+}
@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+func ParseSecretsFile(format string, content string) (EnvVars, error) {
+	switch format {
+	case "dotenv":
+		return ParseDotenv(content)
+	case "json":
+		return ParseJSONEnv(content)
+	case "yaml":
+		return ParseYAMLEnv(content)
+	default:
+		return nil, fmt.Errorf("the '%s' format is not supported", format)
+	}
+}
+
+// nested objects and arrays are rejected
+func ParseJSONEnv(content string) (EnvVars, error) {
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.UseNumber()
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, errors.New("the content must be a JSON object")
+	}
+
+	var vars EnvVars
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := token.(string)
+
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		switch v := value.(type) {
+		case string:
+			vars.Set(key, v)
+		case json.Number:
+			vars.Set(key, v.String())
+		case bool:
+			vars.Set(key, fmt.Sprintf("%t", v))
+		case nil:
+			vars.Set(key, "")
+		default:
+			return nil, fmt.Errorf("the value of '%s' must be a string, number, boolean or null", key)
+		}
+	}
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('}') {
+		return nil, errors.New("the content must be a JSON object")
+	}
+
+	if _, err := decoder.Token(); err == nil {
+		return nil, errors.New("the content must contain a single JSON object")
+	}
+
+	return vars, nil
+}
+
+func ParseYAMLEnv(content string) (EnvVars, error) {
+	var document yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewBufferString(content))
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	if len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("the content must be a YAML map")
+	}
+
+	var vars EnvVars
+	mapping := document.Content[0].Content
+	for i := 0; i+1 < len(mapping); i += 2 {
+		key, value := mapping[i], mapping[i+1]
+		if key.Kind != yaml.ScalarNode {
+			return nil, errors.New("the keys of the YAML map must be scalar values")
+		}
+
+		switch {
+		case value.Kind == yaml.ScalarNode && value.Tag == "!!null":
+			vars.Set(key.Value, "")
+		case value.Kind == yaml.ScalarNode:
+			vars.Set(key.Value, value.Value)
+		default:
+			return nil, fmt.Errorf("the value of '%s' must be a scalar value", key.Value)
+		}
+	}
+
+	return vars, nil
+}
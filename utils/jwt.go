@@ -14,6 +14,12 @@ type JWTTokenClaim struct {
 	jwt.StandardClaims
 }
 
+type JWTInviteClaim struct {
+	Email          string `json:"email"`
+	OrganizationID string `json:"organizationID"`
+	jwt.StandardClaims
+}
+
 type JWTSessionClaim struct {
 	Email  string `json:"email"`
 	Name   string `json:"name"`
@@ -61,6 +67,47 @@ func ValidateUserToken(userToken string) (*JWTTokenClaim, error) {
 	return claims, nil
 }
 
+func GenerateInviteToken(email string, organizationID string) (string, time.Time, error) {
+	exp := time.Now().Add(time.Hour * 24 * 7)
+	claims := JWTInviteClaim{
+		Email:          email,
+		OrganizationID: organizationID,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: exp.Unix(),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(JWT_SECRET_KEY)
+	return tokenString, exp, err
+}
+
+func ValidateInviteToken(inviteToken string) (*JWTInviteClaim, error) {
+	if len(inviteToken) == 0 {
+		return nil, errors.New("no token was provided")
+	}
+
+	token, err := jwt.ParseWithClaims(
+		inviteToken,
+		&JWTInviteClaim{},
+		func(_ *jwt.Token) (interface{}, error) {
+			return JWT_SECRET_KEY, nil
+		},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*JWTInviteClaim)
+	if !ok {
+		return nil, errors.New("unable to parse invite token")
+	} else if claims.ExpiresAt < time.Now().Local().Unix() {
+		return nil, errors.New("token expired")
+	}
+
+	return claims, nil
+}
+
 func ValidateSessionToken(jwtCookie string) (*JWTSessionClaim, error) {
 	if len(jwtCookie) == 0 {
 		return nil, errors.New("you must be logged in order to do that")
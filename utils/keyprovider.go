@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+type KeyProvider interface {
+	// stored with every wrapped data key so it can be unwrapped after another provider becomes active
+	KeyID() string
+	WrapKey(dataKey []byte) ([]byte, error)
+	UnwrapKey(wrappedKey []byte) ([]byte, error)
+}
+
+// the nonce is prepended to the wrapped key
+type AESKeyProvider struct {
+	id  string
+	key []byte
+}
+
+func NewAESKeyProvider(id string, key []byte) (*AESKeyProvider, error) {
+	switch len(key) {
+	case 16, 24, 32:
+		return &AESKeyProvider{id: id, key: key}, nil
+	default:
+		return nil, fmt.Errorf("the '%s' master key must be 16, 24 or 32 bytes long, not %d", id, len(key))
+	}
+}
+
+func (p *AESKeyProvider) KeyID() string {
+	return p.id
+}
+
+func (p *AESKeyProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	ciphertext, nonce, err := seal(p.key, dataKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, ciphertext...), nil
+}
+
+func (p *AESKeyProvider) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	if len(wrappedKey) < 12 {
+		return nil, fmt.Errorf("the wrapped key is too short to have been wrapped by the '%s' master key", p.id)
+	}
+	return open(p.key, wrappedKey[12:], wrappedKey[:12], nil)
+}
+
+// the file holds the raw key or the key encoded as base64
+func NewFileKeyProvider(id string, path string) (*AESKeyProvider, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the '%s' master key file: %w", id, err)
+	}
+
+	key := []byte(strings.TrimSpace(string(contents)))
+	if decodedKey, err := base64.StdEncoding.DecodeString(string(key)); err == nil && len(decodedKey) == 32 {
+		key = decodedKey
+	}
+
+	return NewAESKeyProvider(id, key)
+}
+
+// the salt must not change while any data key wrapped by the derived key exists
+func NewPassphraseKeyProvider(id string, passphrase string, salt string) (*AESKeyProvider, error) {
+	if len(passphrase) == 0 || len(salt) < 16 {
+		return nil, fmt.Errorf("the '%s' master key requires a passphrase and a salt of at least 16 characters", id)
+	}
+
+	return NewAESKeyProvider(id, argon2.IDKey([]byte(passphrase), []byte(salt), 3, 64*1024, 4, 32))
+}
+
+var ActiveKeyProvider = getActiveKeyProvider()
+
+// includes the RETIRED_ENCRYPTION_KEYS until their data keys have been re-wrapped
+var KeyProviders = getKeyProviders()
+
+type KeyProviderConfig struct {
+	Provider   string `json:"provider"`
+	ID         string `json:"id"`
+	Key        string `json:"key"`
+	File       string `json:"file"`
+	Passphrase string `json:"passphrase"`
+	Salt       string `json:"salt"`
+	Address    string `json:"address"`
+	KeyName    string `json:"keyName"`
+	Token      string `json:"token"`
+}
+
+// key IDs are qualified by the provider so different providers can share an ID
+func NewKeyProvider(config KeyProviderConfig) (KeyProvider, error) {
+	if len(config.ID) == 0 {
+		return nil, fmt.Errorf("the '%s' master key requires an ID", config.Provider)
+	}
+
+	id := config.Provider + ":" + config.ID
+	switch config.Provider {
+	case "env":
+		return NewAESKeyProvider(id, []byte(config.Key))
+	case "file":
+		return NewFileKeyProvider(id, config.File)
+	case "passphrase":
+		return NewPassphraseKeyProvider(id, config.Passphrase, config.Salt)
+	case "transit":
+		return NewTransitKeyProvider(id, config.Address, config.KeyName, config.Token)
+	default:
+		return nil, fmt.Errorf(
+			"the '%s' master key's provider must be one of the following: env, file, passphrase or transit, not '%s'",
+			config.ID, config.Provider,
+		)
+	}
+}
+
+// KEY_PROVIDER is one of env (default), file, passphrase or transit
+func getActiveKeyProvider() KeyProvider {
+	config := KeyProviderConfig{Provider: os.Getenv("KEY_PROVIDER"), ID: os.Getenv("ENCRYPTION_KEY_ID")}
+	if len(config.Provider) == 0 {
+		config.Provider = "env"
+	}
+	if len(config.ID) == 0 {
+		config.ID = "default"
+	}
+
+	switch config.Provider {
+	case "env":
+		config.Key = GetEnv("ENCRYPTION_KEY")
+	case "file":
+		config.File = GetEnv("ENCRYPTION_KEY_FILE")
+	case "passphrase":
+		config.Passphrase, config.Salt = GetEnv("ENCRYPTION_PASSPHRASE"), GetEnv("ENCRYPTION_PASSPHRASE_SALT")
+	case "transit":
+		config.Address, config.KeyName, config.Token = GetEnv("TRANSIT_ADDRESS"), GetEnv("TRANSIT_KEY_NAME"), GetEnv("TRANSIT_TOKEN")
+	default:
+		log.Fatalf("The ENV 'KEY_PROVIDER' must be one of the following: env, file, passphrase or transit, not '%s'!", config.Provider)
+	}
+
+	provider, err := NewKeyProvider(config)
+	if err != nil {
+		log.Fatalf("Unable to set up the master key: %s", err.Error())
+	}
+
+	return provider
+}
+
+// e.g. [{"provider": "env", "id": "2023", "key": "..."}]
+func NewRetiredKeyProviders(retiredKeys string) ([]KeyProvider, error) {
+	var configs []KeyProviderConfig
+	if err := json.Unmarshal([]byte(retiredKeys), &configs); err != nil {
+		return nil, fmt.Errorf("the retired master keys must be a JSON array of master key configurations: %w", err)
+	}
+
+	providers := make([]KeyProvider, 0, len(configs))
+	for _, config := range configs {
+		if len(config.Provider) == 0 {
+			return nil, fmt.Errorf("the '%s' retired master key must specify its provider", config.ID)
+		}
+
+		provider, err := NewKeyProvider(config)
+		if err != nil {
+			return nil, err
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return providers, nil
+}
+
+func getKeyProviders() map[string]KeyProvider {
+	providers := map[string]KeyProvider{ActiveKeyProvider.KeyID(): ActiveKeyProvider}
+
+	retiredKeys, ok := os.LookupEnv("RETIRED_ENCRYPTION_KEYS")
+	if !ok || len(retiredKeys) == 0 {
+		return providers
+	}
+
+	retiredProviders, err := NewRetiredKeyProviders(retiredKeys)
+	if err != nil {
+		log.Fatalf("Unable to set up the retired master keys: %s", err.Error())
+	}
+
+	for _, provider := range retiredProviders {
+		if _, exists := providers[provider.KeyID()]; exists {
+			log.Fatalf("The ENV 'RETIRED_ENCRYPTION_KEYS' contains a duplicate or active key ID: '%s'!", provider.KeyID())
+		}
+
+		providers[provider.KeyID()] = provider
+	}
+
+	return providers
+}
+
+func SetActiveKeyProvider(provider KeyProvider) {
+	KeyProviders[provider.KeyID()] = provider
+	ActiveKeyProvider = provider
+}
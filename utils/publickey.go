@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"crypto/ecdh"
+	"encoding/base64"
+)
+
+func ParseX25519PublicKey(encodedKey string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := ecdh.X25519().NewPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return publicKey.Bytes(), nil
+}
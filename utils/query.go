@@ -2,48 +2,95 @@ package utils
 
 import "github.com/google/uuid"
 
-const FindSecretsByEnvIDQuery = `
+// can't recurse more than 10 levels since a project has at most 10 environments
+const environmentLineageCTE = `
+WITH RECURSIVE lineage AS (
+	SELECT e.id, e.name, e.project_id, e.parent_id, 0 AS depth
+	FROM environments e
+	WHERE e.project_id = ? AND e.id = ?
+	UNION ALL
+	SELECT p.id, p.name, p.project_id, p.parent_id, l.depth + 1
+	FROM environments p
+	JOIN lineage l ON p.id = l.parent_id AND p.project_id = l.project_id
+	WHERE l.depth < 10
+)`
+
+const FindEnvironmentLineageQuery = environmentLineageCTE + `
+SELECT id
+FROM lineage
+ORDER BY depth;
+`
+
+const environmentSecretValueColumns = `
+		COALESCE(es.value, s.value) AS value,
+		CASE WHEN es.value IS NULL THEN s.nonce ELSE es.nonce END AS nonce,
+		CASE WHEN es.value IS NULL THEN s.seal_version ELSE es.seal_version END AS seal_version,
+		CASE WHEN es.value IS NULL THEN NULL ELSE es.environment_id END AS value_environment_id`
+
+// a key within a nearer environment overrides the same key within a farther one
+const findMergedSecretsQuery = environmentLineageCTE + `
 SELECT * 
 FROM (
-	SELECT 
+	SELECT DISTINCT ON (s.key)
 		s.id,
+		s.project_id,
 		s.user_id,
 		s.key,
-		s.value,
-        s.nonce,
+		s.version,` + environmentSecretValueColumns + `,
 		s.created_at,
 		s.updated_at,
-		jsonb_agg(envs) as environments
-	FROM secrets s
-	JOIN environment_secrets es ON s.id = es.secret_id
-	JOIN environments envs on es.environment_id = envs.id
-	WHERE s.user_id = ?
-	GROUP BY s.id
-    ORDER BY s.created_at
+		l.id AS source_environment_id,
+		l.name AS source_environment_name,
+		(
+			SELECT jsonb_agg(envs)
+			FROM environment_secrets ses
+			JOIN environments envs ON ses.environment_id = envs.id
+			WHERE ses.secret_id = s.id
+		) AS environments
+	FROM lineage l
+	JOIN environment_secrets es ON l.id = es.environment_id
+	JOIN secrets s ON s.id = es.secret_id AND s.project_id = l.project_id
+`
+
+const FindSecretsByEnvIDQuery = findMergedSecretsQuery + `
+	ORDER BY s.key, l.depth
 ) r
-WHERE r.environments @> ?;
+ORDER BY r.created_at;
 `
 
-const FindSecretsByEnvIDAndSecretKeyQuery = `
-SELECT * 
-FROM (
-	SELECT 
-		s.id,
-		s.user_id,
-		s.key,
-		s.value,
-        s.nonce,
-		s.created_at,
-		s.updated_at,
-		jsonb_agg(envs) as environments
-	FROM secrets s
-	JOIN environment_secrets es ON s.id = es.secret_id
-	JOIN environments envs on es.environment_id = envs.id
-	WHERE s.user_id = ? AND s.key ILIKE ?
-	GROUP BY s.id
-    ORDER BY s.created_at
+const FindSecretsByEnvIDAndSecretKeyQuery = findMergedSecretsQuery + `
+	WHERE s.key ILIKE ?
+	ORDER BY s.key, l.depth
 ) r
-WHERE r.environments @> ?;
+ORDER BY r.created_at;
+`
+
+const FindSecretVersionsByEnvIDQuery = environmentLineageCTE + `
+SELECT DISTINCT ON (s.key)
+	s.id,
+	s.key,
+	s.version,
+	l.id AS source_environment_id
+FROM lineage l
+JOIN environment_secrets es ON l.id = es.environment_id
+JOIN secrets s ON s.id = es.secret_id AND s.project_id = l.project_id
+ORDER BY s.key, l.depth;
+`
+
+const FindOwnSecretsByEnvIDQuery = `
+SELECT
+	s.id,
+	s.project_id,
+	s.user_id,
+	s.key,
+	s.version,` + environmentSecretValueColumns + `,
+	s.created_at,
+	s.updated_at,
+	es.environment_id AS source_environment_id
+FROM environment_secrets es
+JOIN secrets s ON s.id = es.secret_id
+WHERE s.project_id = ? AND es.environment_id = ?
+ORDER BY s.created_at;
 `
 
 func GenerateJSONIDString(id uuid.UUID) string {
@@ -65,6 +112,7 @@ func GenerateFindSecretByEnvIDsQuery(ids []uuid.UUID) string {
 	       FROM (
 	        SELECT
 		        s.id,
+		        s.project_id,
 		        s.user_id,
 		        s.key,
 		        s.value,
@@ -74,7 +122,7 @@ func GenerateFindSecretByEnvIDsQuery(ids []uuid.UUID) string {
 	        FROM secrets s
 	        JOIN environment_secrets es ON s.id = es.secret_id
 	        JOIN environments envs on es.environment_id = envs.id
-	        WHERE s.user_id = ?
+	        WHERE s.project_id = ?
 	        GROUP BY s.id
 	       ) r
 	       WHERE `
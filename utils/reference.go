@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrSecretReferenceCycle    = errors.New("the reference forms a cycle")
+	ErrSecretReferenceDangling = errors.New("the referenced secret doesn't exist or can't be accessed")
+)
+
+// "$${...}" escapes a reference
+var secretReferenceRegex = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+
+type SecretReference struct {
+	Project     string
+	Environment string
+	Key         string
+}
+
+func (ref SecretReference) String() string {
+	if len(ref.Project) == 0 {
+		return "${" + ref.Key + "}"
+	}
+	return "${" + ref.Project + "." + ref.Environment + "." + ref.Key + "}"
+}
+
+type SecretReferenceError struct {
+	Err       error
+	Key       string
+	Reference SecretReference
+}
+
+func (e *SecretReferenceError) Error() string {
+	return fmt.Sprintf("unable to resolve the '%s' reference within the '%s' secret: %v", e.Reference, e.Key, e.Err)
+}
+
+func (e *SecretReferenceError) Unwrap() error {
+	return e.Err
+}
+
+// whether it's a cross project reference is up to the resolver, depending on whether the environment can be read
+func ParseSecretReference(reference string) SecretReference {
+	if parts := strings.SplitN(reference, ".", 3); len(parts) == 3 &&
+		nameRegex.MatchString(parts[0]) && nameRegex.MatchString(parts[1]) && len(parts[2]) > 0 {
+		return SecretReference{Project: parts[0], Environment: parts[1], Key: parts[2]}
+	}
+	return SecretReference{Key: reference}
+}
+
+// a nil result means the environment doesn't exist or can't be accessed
+type SecretEnvironmentLoader func(project string, environment string) (EnvVars, error)
+
+// references within a referenced value are resolved against the environment it belongs to
+type SecretResolver struct {
+	Load         SecretEnvironmentLoader
+	environments map[[2]string]map[string]string
+	resolved     map[SecretReference]string
+	resolving    map[SecretReference]bool
+	root         [2]string
+	referenced   []SecretReference
+}
+
+func NewSecretResolver(load SecretEnvironmentLoader) *SecretResolver {
+	return &SecretResolver{
+		Load:         load,
+		environments: make(map[[2]string]map[string]string),
+		resolved:     make(map[SecretReference]string),
+		resolving:    make(map[SecretReference]bool),
+	}
+}
+
+func HasSecretReferences(value string) bool {
+	return secretReferenceRegex.MatchString(value)
+}
+
+func (r *SecretResolver) Resolve(project string, environment string, vars EnvVars) (EnvVars, error) {
+	values := make(map[string]string, len(vars))
+	for _, envVar := range vars {
+		values[envVar.Key] = envVar.Value
+	}
+	r.root = [2]string{project, environment}
+	r.environments[r.root] = values
+
+	resolvedVars := make(EnvVars, 0, len(vars))
+	for _, envVar := range vars {
+		value, err := r.resolve(SecretReference{Project: project, Environment: environment, Key: envVar.Key})
+		if err != nil {
+			return nil, err
+		}
+		resolvedVars = append(resolvedVars, EnvVar{Key: envVar.Key, Value: value})
+	}
+
+	return resolvedVars, nil
+}
+
+func (r *SecretResolver) LoadedOtherEnvironments() bool {
+	loaded := 0
+	for _, values := range r.environments {
+		if values != nil {
+			loaded++
+		}
+	}
+	return loaded > 1
+}
+
+func (r *SecretResolver) ReferencedSecrets() []SecretReference {
+	return r.referenced
+}
+
+func (r *SecretResolver) lookup(project string, environment string) (map[string]string, error) {
+	if values, ok := r.environments[[2]string{project, environment}]; ok {
+		return values, nil
+	}
+
+	vars, err := r.Load(project, environment)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]string
+	if vars != nil {
+		values = make(map[string]string, len(vars))
+		for _, envVar := range vars {
+			values[envVar.Key] = envVar.Value
+		}
+	}
+	r.environments[[2]string{project, environment}] = values
+
+	return values, nil
+}
+
+// "ref" must be fully qualified
+func (r *SecretResolver) resolve(ref SecretReference) (string, error) {
+	if value, ok := r.resolved[ref]; ok {
+		return value, nil
+	}
+
+	values, err := r.lookup(ref.Project, ref.Environment)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := values[ref.Key]
+	if !ok {
+		return "", ErrSecretReferenceDangling
+	}
+
+	r.resolving[ref] = true
+	defer delete(r.resolving, ref)
+
+	var resolveErr error
+	resolvedValue := secretReferenceRegex.ReplaceAllStringFunc(value, func(match string) string {
+		if resolveErr != nil {
+			return match
+		}
+
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+
+		name := match[2 : len(match)-1]
+		target := ParseSecretReference(name)
+		if len(target.Project) > 0 {
+			values, err := r.lookup(target.Project, target.Environment)
+			if err != nil {
+				resolveErr = err
+				return match
+			}
+			if values == nil {
+				target = SecretReference{Key: name}
+			}
+		}
+
+		reference := target
+		if len(target.Project) == 0 {
+			target.Project, target.Environment = ref.Project, ref.Environment
+		}
+
+		if r.resolving[target] {
+			resolveErr = &SecretReferenceError{Err: ErrSecretReferenceCycle, Key: ref.Key, Reference: reference}
+			return match
+		}
+
+		targetValue, err := r.resolve(target)
+		if err != nil {
+			if errors.Is(err, ErrSecretReferenceDangling) && !errors.As(err, new(*SecretReferenceError)) {
+				err = &SecretReferenceError{Err: err, Key: ref.Key, Reference: reference}
+			}
+			resolveErr = err
+			return match
+		}
+
+		return targetValue
+	})
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+
+	r.resolved[ref] = resolvedValue
+	if [2]string{ref.Project, ref.Environment} != r.root {
+		r.referenced = append(r.referenced, ref)
+	}
+	return resolvedValue, nil
+}
+
+func SecretReferenceErrorCode(err error) (ErrorResponseCode, bool) {
+	switch {
+	case errors.Is(err, ErrSecretReferenceCycle):
+		return SecretReferenceCycle, true
+	case errors.Is(err, ErrSecretReferenceDangling):
+		return SecretReferenceDangling, true
+	default:
+		return Unknown, false
+	}
+}
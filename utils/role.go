@@ -0,0 +1,93 @@
+package utils
+
+const (
+	RoleOwner     = "owner"
+	RoleAdmin     = "admin"
+	RoleDeveloper = "developer"
+	RoleViewer    = "viewer"
+)
+
+const (
+	AccessNone  = "none"
+	AccessRead  = "read"
+	AccessWrite = "write"
+)
+
+type Permission int
+
+const (
+	// view projects, environments and secrets
+	ReadResources Permission = iota
+	// create, update and delete secrets
+	WriteSecrets
+	// create, update and delete environments, as well as restrict them per role
+	ManageEnvironments
+	// create, update and delete projects
+	ManageProjects
+	// invite and remove members, as well as change their roles
+	ManageMembers
+	// view the audit log of a project
+	ReadAuditLog
+	// rename and delete the organization
+	ManageOrganization
+)
+
+var roleRanks = map[string]int{
+	RoleViewer:    1,
+	RoleDeveloper: 2,
+	RoleAdmin:     3,
+	RoleOwner:     4,
+}
+
+var permissionRoles = map[Permission]string{
+	ReadResources:      RoleViewer,
+	WriteSecrets:       RoleDeveloper,
+	ManageEnvironments: RoleAdmin,
+	ManageProjects:     RoleAdmin,
+	ManageMembers:      RoleAdmin,
+	ReadAuditLog:       RoleAdmin,
+	ManageOrganization: RoleOwner,
+}
+
+var accessRanks = map[string]int{
+	AccessNone:  0,
+	AccessRead:  1,
+	AccessWrite: 2,
+}
+
+func RoleHasPermission(role string, permission Permission) bool {
+	return roleRanks[role] > 0 && roleRanks[role] >= roleRanks[permissionRoles[permission]]
+}
+
+func RolesWithPermission(permission Permission) []string {
+	var roles []string
+	for role := range roleRanks {
+		if RoleHasPermission(role, permission) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// owners and admins always have write access
+func RoleCanBeRestricted(role string) bool {
+	return role == RoleDeveloper || role == RoleViewer
+}
+
+func DefaultEnvironmentAccess(role string) string {
+	if role == RoleViewer {
+		return AccessRead
+	}
+	return AccessWrite
+}
+
+func MinAccess(a string, b string) string {
+	if accessRanks[a] < accessRanks[b] {
+		return a
+	}
+	return b
+}
+
+func HasAccess(access string, required string) bool {
+	return accessRanks[access] >= accessRanks[required]
+}
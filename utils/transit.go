@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// compatible with Vault's transit secrets engine
+type TransitKeyProvider struct {
+	id      string
+	address string
+	keyName string
+	token   string
+	client  *http.Client
+}
+
+type transitRequest struct {
+	Plaintext  string `json:"plaintext,omitempty"`
+	Ciphertext string `json:"ciphertext,omitempty"`
+}
+
+type transitResponse struct {
+	Data struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+func NewTransitKeyProvider(id string, address string, keyName string, token string) (*TransitKeyProvider, error) {
+	if _, err := url.ParseRequestURI(address); err != nil {
+		return nil, fmt.Errorf("the '%s' transit address isn't a valid url: %w", id, err)
+	}
+
+	if len(keyName) == 0 || len(token) == 0 {
+		return nil, fmt.Errorf("the '%s' transit key requires a key name and a token", id)
+	}
+
+	return &TransitKeyProvider{
+		id:      id,
+		address: strings.TrimSuffix(address, "/"),
+		keyName: keyName,
+		token:   token,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (p *TransitKeyProvider) KeyID() string {
+	return p.id
+}
+
+func (p *TransitKeyProvider) send(operation string, body transitRequest) (transitResponse, error) {
+	var res transitResponse
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return res, err
+	}
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		fmt.Sprintf("%s/v1/transit/%s/%s", p.address, operation, url.PathEscape(p.keyName)),
+		bytes.NewReader(payload),
+	)
+	if err != nil {
+		return res, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", p.token)
+
+	httpRes, err := p.client.Do(req)
+	if err != nil {
+		return res, err
+	}
+	defer httpRes.Body.Close()
+
+	if err := json.NewDecoder(httpRes.Body).Decode(&res); err != nil && httpRes.StatusCode == http.StatusOK {
+		return res, err
+	}
+
+	if httpRes.StatusCode != http.StatusOK {
+		return res, fmt.Errorf(
+			"the transit service responded to the %s request with a %d status: %s",
+			operation, httpRes.StatusCode, strings.Join(res.Errors, ", "),
+		)
+	}
+
+	return res, nil
+}
+
+func (p *TransitKeyProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	res, err := p.send("encrypt", transitRequest{Plaintext: base64.StdEncoding.EncodeToString(dataKey)})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(res.Data.Ciphertext), nil
+}
+
+func (p *TransitKeyProvider) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	res, err := p.send("decrypt", transitRequest{Ciphertext: string(wrappedKey)})
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(res.Data.Plaintext)
+}
@@ -39,6 +39,10 @@ func validateName(fl validator.FieldLevel) bool {
 	return nameRegex.MatchString(fl.Field().String())
 }
 
+func validateSecretKey(fl validator.FieldLevel) bool {
+	return dotenvKeyRegex.MatchString(fl.Field().String())
+}
+
 func Validate() *validator.Validate {
 	if validate == nil {
 		validate = validator.New()
@@ -48,6 +52,9 @@ func Validate() *validator.Validate {
 		if err := validate.RegisterValidation("name", validateName); err != nil {
 			log.Fatalf("Unable to register name validator: %s", err.Error())
 		}
+		if err := validate.RegisterValidation("secretkey", validateSecretKey); err != nil {
+			log.Fatalf("Unable to register secretkey validator: %s", err.Error())
+		}
 		return validate
 	}
 	return validate